@@ -25,3 +25,46 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestDump(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{
+				Token: token.Token{Type: token.IDENT, Literal: "add", Pos: token.Position{Line: 1, Column: 1}},
+				Expression: &CallExpression{
+					Token: token.Token{Type: token.LPAREN, Literal: "("},
+					Function: &Identifier{
+						Token: token.Token{Type: token.IDENT, Literal: "add", Pos: token.Position{Line: 1, Column: 1}},
+						Value: "add",
+					},
+					Arguments: []Expression{
+						&IntegerLiteral{
+							Token: token.Token{Type: token.INT, Literal: "1", Pos: token.Position{Line: 1, Column: 5}},
+							Value: 1,
+						},
+						&PrefixExpression{
+							Token:    token.Token{Type: token.MINUS, Literal: "-", Pos: token.Position{Line: 1, Column: 8}},
+							Operator: "-",
+							Right: &StringLiteral{
+								Token: token.Token{Type: token.STRING, Literal: "", Pos: token.Position{Line: 1, Column: 9}},
+								Value: "",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	expected := `Program @1:1
+  Statements[0]: ExpressionStatement @1:1
+    Expression: CallExpression @1:1
+      Function: Identifier Value="add" @1:1
+      Arguments[0]: IntegerLiteral Value=1 @1:5
+      Arguments[1]: PrefixExpression Operator="-" @1:8
+        Right: StringLiteral Value="" @1:9
+`
+	if got := Dump(program); got != expected {
+		t.Errorf("Dump wrong.\nwant=\n%s\ngot=\n%s", expected, got)
+	}
+}
@@ -0,0 +1,67 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// Dump returns the tree below node, one node per line and indented by
+// depth. Each line shows the field of the parent the node is in, its type,
+// its own values like names and operators, and where it starts.
+func Dump(node Node) string {
+	var out bytes.Buffer
+	dump(&out, 0, "", node)
+	return out.String()
+}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+func dump(out *bytes.Buffer, depth int, label string, node Node) {
+	v := reflect.ValueOf(node)
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return
+	}
+	v = reflect.Indirect(v)
+	t := v.Type()
+
+	fmt.Fprintf(out, "%*s%s%s", 2*depth, "", label, t.Name())
+	for i := 0; i < t.NumField(); i++ {
+		name, field := t.Field(i).Name, v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			if field.String() != "" || name == "Value" {
+				fmt.Fprintf(out, " %s=%q", name, field.String())
+			}
+		case reflect.Int64, reflect.Float64, reflect.Bool:
+			fmt.Fprintf(out, " %s=%v", name, field.Interface())
+		}
+	}
+	if pos := node.Pos(); pos.IsValid() {
+		fmt.Fprintf(out, " @%s", pos)
+	}
+	out.WriteString("\n")
+
+	// The pairs of a hash are shown in source order
+	if hash, ok := node.(*HashLiteral); ok {
+		for i, key := range hash.Keys {
+			dump(out, depth+1, fmt.Sprintf("Key[%d]: ", i), key)
+			dump(out, depth+1, fmt.Sprintf("Value[%d]: ", i), hash.Pairs[key])
+		}
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		name, field := t.Field(i).Name, v.Field(i)
+		switch {
+		case field.Type().Implements(nodeType):
+			if !field.IsNil() {
+				dump(out, depth+1, name+": ", field.Interface().(Node))
+			}
+		case field.Kind() == reflect.Slice && field.Type().Elem().Implements(nodeType):
+			for j := 0; j < field.Len(); j++ {
+				dump(out, depth+1, fmt.Sprintf("%s[%d]: ", name, j), field.Index(j).Interface().(Node))
+			}
+		}
+	}
+}
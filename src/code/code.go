@@ -0,0 +1,321 @@
+// Package code defines the bytecode instructions the compiler emits and the
+// virtual machine executes.
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gorilla/token"
+	"sort"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpGreaterEqual
+	OpLessThan
+	OpLessEqual
+
+	OpMinus
+	OpBang
+	OpBool // replaces the top of the stack by its truthiness
+
+	OpJumpNotTruthy
+	OpJump
+
+	// Let statements define a binding with OpSet*, assignments change an
+	// existing one with OpAssign*. Assignments leave the value on the
+	// stack as the result of the expression.
+	OpGetGlobal
+	OpSetGlobal
+	OpAssignGlobal
+	OpGetLocal
+	OpSetLocal
+	OpAssignLocal
+	OpClearLocal // undefines a local, at the start of a block that declares it
+
+	// Locals that a closure captures live in cells, so the function that
+	// declares them and every closure share a single binding.
+	OpMakeCell
+	OpGetCell
+	OpSetCell
+	OpAssignCell
+	OpGetFree
+	OpAssignFree
+	OpGetFreeCell // pushes the cell of a free variable, to capture it again
+
+	OpArray
+	OpHash
+	OpTemplate
+	OpIndex
+	OpSetIndex
+	OpDup2
+
+	OpCall
+	OpReturnValue
+	OpReturn // ends the main program without a value
+	OpClosure
+
+	OpIter
+	OpIterNext
+	OpIterEnd
+
+	OpPushHandler
+	OpPopHandler
+	OpThrow
+	OpRethrow
+	OpCaught
+
+	OpImport
+	OpMember
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+	OpMod: {"OpMod", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+	OpBool:  {"OpBool", []int{}},
+
+	// The operand is the offset to jump to
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+
+	OpGetGlobal:    {"OpGetGlobal", []int{2}},
+	OpSetGlobal:    {"OpSetGlobal", []int{2}},
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+	OpGetLocal:     {"OpGetLocal", []int{1}},
+	OpSetLocal:     {"OpSetLocal", []int{1}},
+	OpAssignLocal:  {"OpAssignLocal", []int{1}},
+	OpClearLocal:   {"OpClearLocal", []int{1}},
+
+	OpMakeCell:    {"OpMakeCell", []int{1}},
+	OpGetCell:     {"OpGetCell", []int{1}},
+	OpSetCell:     {"OpSetCell", []int{1}},
+	OpAssignCell:  {"OpAssignCell", []int{1}},
+	OpGetFree:     {"OpGetFree", []int{1}},
+	OpAssignFree:  {"OpAssignFree", []int{1}},
+	OpGetFreeCell: {"OpGetFreeCell", []int{1}},
+
+	// The operand is the number of elements, of pairs for a hash, or of
+	// parts for a template literal
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+	OpTemplate: {"OpTemplate", []int{2}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSetIndex: {"OpSetIndex", []int{}},
+	OpDup2:     {"OpDup2", []int{}},
+
+	// The operand is the number of arguments
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	// The operands are the constant index of the function and the number
+	// of cells it captures
+	OpClosure: {"OpClosure", []int{2, 1}},
+
+	// The operands of OpIterNext are the offset to jump to when the
+	// iterator is exhausted and the number of values to push: 2 for
+	// key and value, 1 for the value alone (the key of a hash).
+	OpIter:     {"OpIter", []int{}},
+	OpIterNext: {"OpIterNext", []int{2, 1}},
+	OpIterEnd:  {"OpIterEnd", []int{}},
+
+	// The operand is the offset of the catch code
+	OpPushHandler: {"OpPushHandler", []int{2}},
+	OpPopHandler:  {"OpPopHandler", []int{}},
+	OpThrow:       {"OpThrow", []int{}},
+	OpRethrow:     {"OpRethrow", []int{}},
+	OpCaught:      {"OpCaught", []int{}},
+
+	// The operand is the constant index of the import path or member name
+	OpImport: {"OpImport", []int{2}},
+	OpMember: {"OpMember", []int{2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes an instruction. Operands are stored big-endian.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction and returns them with
+// the number of bytes they take.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
+
+// SourceMap records the source position of the node each instruction was
+// compiled from, so runtime errors can point at the code that caused them.
+type SourceMap struct {
+	offsets   []int
+	positions []token.Position
+}
+
+// Add records that the instructions from offset on come from pos. Offsets
+// must be added in increasing order.
+func (sm *SourceMap) Add(offset int, pos token.Position) {
+	n := len(sm.offsets)
+	if n > 0 && sm.positions[n-1] == pos {
+		return
+	}
+	if n > 0 && sm.offsets[n-1] == offset {
+		sm.positions[n-1] = pos
+		return
+	}
+	sm.offsets = append(sm.offsets, offset)
+	sm.positions = append(sm.positions, pos)
+}
+
+// Lookup returns the source position of the instruction at offset.
+func (sm *SourceMap) Lookup(offset int) token.Position {
+	i := sort.SearchInts(sm.offsets, offset+1) - 1
+	if i < 0 {
+		return token.Position{}
+	}
+	return sm.positions[i]
+}
+
+// Truncate forgets every position recorded at or after offset.
+func (sm *SourceMap) Truncate(offset int) {
+	i := sort.SearchInts(sm.offsets, offset)
+	sm.offsets = sm.offsets[:i]
+	sm.positions = sm.positions[:i]
+}
@@ -0,0 +1,942 @@
+// Package compiler turns a parsed program into bytecode for the vm package.
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"gorilla/ast"
+	"gorilla/code"
+	"gorilla/evaluator"
+	"gorilla/object"
+	"gorilla/token"
+	"strings"
+)
+
+// maxLocals is the number of local slots an OpGetLocal operand can address.
+const maxLocals = 256
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	">=": code.OpGreaterEqual,
+	"<":  code.OpLessThan,
+	"<=": code.OpLessEqual,
+}
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// pos is the position of the innermost node being compiled. Every
+	// instruction is recorded in the source map with it, so that runtime
+	// errors point where the tree-walking evaluator would.
+	pos token.Position
+
+	// builtins holds the constant index of each builtin used so far
+	builtins map[string]int
+
+	// err is the first operand found too big for its instruction, which
+	// Compile returns instead of letting it be cut short.
+	err error
+}
+
+// CompilationScope holds the instructions of the function being compiled.
+type CompilationScope struct {
+	instructions code.Instructions
+	sourceMap    *code.SourceMap
+	callNames    map[int]string
+
+	loops []*loop
+	tries []tryBlock
+}
+
+// loop tracks the jumps of break and continue statements.
+type loop struct {
+	start  int   // where continue jumps to
+	breaks []int // jumps to patch with the end of the loop
+	tries  int   // number of enclosing try blocks when the loop started
+}
+
+// tryBlock is a try or catch block that return, break and continue have
+// to leave cleanly: by popping its error handler and running its finally
+// block first.
+type tryBlock struct {
+	handler bool
+	finally *ast.BlockStatement
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState returns a compiler that continues with the globals and
+// constants of an earlier compilation, as the REPL does between lines.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	mainScope := CompilationScope{
+		instructions: code.Instructions{},
+		sourceMap:    &code.SourceMap{},
+		callNames:    map[int]string{},
+	}
+
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		scopes:      []CompilationScope{mainScope},
+		builtins:    map[string]int{},
+	}
+}
+
+// Compile compiles node, or reports why it can't be compiled.
+func (c *Compiler) Compile(node ast.Node) error {
+	if err := c.compile(node); err != nil {
+		return err
+	}
+	return c.err
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	prev := c.pos
+	if pos := node.Pos(); pos.IsValid() {
+		c.pos = pos
+	}
+	defer func() { c.pos = prev }()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		c.symbolTable.mainLocals = nil
+		for _, name := range declaredNames(node) {
+			c.symbolTable.Define(name)
+		}
+
+		// The value of the program is the value of its last statement,
+		// when that is an expression.
+		for i, s := range node.Statements {
+			if es, ok := s.(*ast.ExpressionStatement); ok && i == len(node.Statements)-1 {
+				if err := c.Compile(es.Expression); err != nil {
+					return err
+				}
+				c.emit(code.OpReturnValue)
+				return nil
+			}
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpReturn)
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.define(node.Name.Value)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		if err := c.leaveTries(0); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		return c.compileLoopControl(node.(ast.Statement))
+
+	case *ast.ImportStatement:
+		c.emit(code.OpImport, c.addConstant(&object.String{Value: node.Path.Value}))
+		c.define(evaluator.ImportName(node))
+
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogical(node)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+
+	case *ast.IfExpression:
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+
+		jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.compileBlockValue(node.Consequence); err != nil {
+			return err
+		}
+
+		jump := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else if err := c.compileBlockValue(node.Alternative); err != nil {
+			return err
+		}
+
+		c.changeOperand(jump, len(c.currentInstructions()))
+
+	case *ast.WhileExpression:
+		return c.compileWhile(node)
+
+	case *ast.ForExpression:
+		return c.compileFor(node)
+
+	case *ast.TryExpression:
+		return c.compileTry(node)
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			symbol = c.symbolTable.DefineImplicit(node.Value)
+		}
+		c.loadSymbol(symbol)
+
+	case *ast.AssignExpression:
+		return c.compileAssign(node)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.TemplateLiteral:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpTemplate, len(node.Parts))
+
+	case *ast.HashLiteral:
+		for _, key := range node.Keys {
+			if err := c.Compile(key); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[key]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Keys))
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.MemberExpression:
+		if err := c.Compile(node.Object); err != nil {
+			return err
+		}
+		c.emit(code.OpMember, c.addConstant(&object.String{Value: node.Member.Value}))
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node)
+
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+
+		call := c.emit(code.OpCall, len(node.Arguments))
+		if ident, ok := node.Function.(*ast.Identifier); ok {
+			c.scopes[c.scopeIndex].callNames[call] = ident.Value
+		}
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+// compileBlockValue compiles a block whose value is used: the value of its
+// last statement if that is an expression, null otherwise.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	for i, s := range block.Statements {
+		if es, ok := s.(*ast.ExpressionStatement); ok && i == len(block.Statements)-1 {
+			return c.Compile(es.Expression)
+		}
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpNull)
+	return nil
+}
+
+// compileLogical compiles && and ||, which evaluate their right operand only
+// when the left one doesn't decide the result, and produce a boolean.
+func (c *Compiler) compileLogical(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if node.Operator == "&&" {
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(code.OpBool)
+		jump := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jump, len(c.currentInstructions()))
+		return nil
+	}
+
+	c.emit(code.OpTrue)
+	jump := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	c.emit(code.OpBool)
+	c.changeOperand(jump, len(c.currentInstructions()))
+	return nil
+}
+
+// A loop keeps its latest result on the stack: the value of the last run
+// of its body. Each run pops the previous one, and break and continue push
+// null in place of the body's value.
+func (c *Compiler) compileWhile(node *ast.WhileExpression) error {
+	c.emit(code.OpNull)
+
+	start := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, 9999)
+	c.emit(code.OpPop)
+
+	l := c.enterLoop(start)
+	if err := c.compileBlockValue(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	c.changeOperand(exit, len(c.currentInstructions()))
+	c.leaveLoop(l)
+	return nil
+}
+
+func (c *Compiler) compileFor(node *ast.ForExpression) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+	c.emit(code.OpNull)
+
+	start := len(c.currentInstructions())
+	values := 1
+	if node.Key != nil {
+		values = 2
+	}
+	next := c.emit(code.OpIterNext, 9999, values)
+
+	c.define(node.Value.Value)
+	if node.Key != nil {
+		c.define(node.Key.Value)
+	}
+
+	l := c.enterLoop(start)
+	if err := c.compileBlockValue(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	c.changeOperand(next, len(c.currentInstructions()), values)
+	c.leaveLoop(l)
+	c.emit(code.OpIterEnd)
+	return nil
+}
+
+func (c *Compiler) enterLoop(start int) *loop {
+	scope := &c.scopes[c.scopeIndex]
+	l := &loop{start: start, tries: len(scope.tries)}
+	scope.loops = append(scope.loops, l)
+	return l
+}
+
+// leaveLoop points the breaks of l at the current end of the instructions.
+func (c *Compiler) leaveLoop(l *loop) {
+	end := len(c.currentInstructions())
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, end)
+	}
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+}
+
+func (c *Compiler) compileLoopControl(node ast.Statement) error {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return fmt.Errorf("%s outside loop", node.TokenLiteral())
+	}
+	l := loops[len(loops)-1]
+
+	if err := c.leaveTries(l.tries); err != nil {
+		return err
+	}
+
+	c.emit(code.OpNull)
+	if _, ok := node.(*ast.BreakStatement); ok {
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+	} else {
+		c.emit(code.OpJump, l.start)
+	}
+	return nil
+}
+
+// compileTry compiles
+//
+//	try { block } catch (e) { catch } finally { finally }
+//
+// The block runs under an error handler that jumps to the catch code. The
+// finally block is copied onto every way out: after the block, after the
+// catch block, before an error is raised again, and before any return,
+// break or continue that leaves the try (see leaveTries).
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	handler := c.emit(code.OpPushHandler, 9999)
+	c.enterTry(true, node.Finally)
+	if err := c.compileBlockValue(node.Block); err != nil {
+		return err
+	}
+	c.leaveTry()
+	c.emit(code.OpPopHandler)
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	jumps := []int{c.emit(code.OpJump, 9999)}
+
+	// The VM jumps here with the error on the stack
+	c.changeOperand(handler, len(c.currentInstructions()))
+
+	if node.Catch == nil {
+		if err := c.compileFinally(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpRethrow)
+	} else {
+		// The catch block is a scope of its own, as a function body is, so
+		// that its parameter and its names don't leak out of it
+		var params []string
+		if node.CatchParam != nil {
+			params = append(params, node.CatchParam.Value)
+		}
+		if err := c.enterBlock(node.Catch, params...); err != nil {
+			return err
+		}
+		if node.CatchParam != nil {
+			c.emit(code.OpCaught)
+			c.define(node.CatchParam.Value)
+		} else {
+			c.emit(code.OpPop)
+		}
+
+		hasFinally := node.Finally != nil
+		var catchHandler int
+		if hasFinally {
+			catchHandler = c.emit(code.OpPushHandler, 9999)
+		}
+		c.enterTry(hasFinally, node.Finally)
+		if err := c.compileBlockValue(node.Catch); err != nil {
+			return err
+		}
+		c.leaveTry()
+		c.leaveBlock()
+
+		if hasFinally {
+			c.emit(code.OpPopHandler)
+			if err := c.compileFinally(node.Finally); err != nil {
+				return err
+			}
+			jumps = append(jumps, c.emit(code.OpJump, 9999))
+
+			// An error raised by the catch block
+			c.changeOperand(catchHandler, len(c.currentInstructions()))
+			if err := c.compileFinally(node.Finally); err != nil {
+				return err
+			}
+			c.emit(code.OpRethrow)
+		}
+	}
+
+	end := len(c.currentInstructions())
+	for _, jump := range jumps {
+		c.changeOperand(jump, end)
+	}
+	return nil
+}
+
+// enterBlock starts the scope of a block that binds params and the names
+// it declares. They get new slots in the enclosing function, which are
+// cleared every time the block runs, so that each run has fresh bindings.
+func (c *Compiler) enterBlock(block *ast.BlockStatement, params ...string) error {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+
+	captured := capturedNames(block)
+	for _, name := range append(params, declaredNames(block)...) {
+		if _, ok := c.symbolTable.store[name]; ok {
+			continue
+		}
+
+		var symbol Symbol
+		if captured[name] {
+			symbol = c.symbolTable.DefineCell(name)
+		} else {
+			symbol = c.symbolTable.Define(name)
+		}
+		if symbol.Index >= maxLocals {
+			return fmt.Errorf("too many local variables in catch block")
+		}
+
+		c.emit(code.OpClearLocal, symbol.Index)
+		if symbol.Cell {
+			c.emit(code.OpMakeCell, symbol.Index)
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) leaveBlock() {
+	c.symbolTable = c.symbolTable.Outer
+}
+
+// compileFinally compiles a finally block, whose value is discarded.
+func (c *Compiler) compileFinally(finally *ast.BlockStatement) error {
+	if finally == nil {
+		return nil
+	}
+	return c.Compile(finally)
+}
+
+func (c *Compiler) enterTry(handler bool, finally *ast.BlockStatement) {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = append(scope.tries, tryBlock{handler: handler, finally: finally})
+}
+
+func (c *Compiler) leaveTry() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = scope.tries[:len(scope.tries)-1]
+}
+
+// leaveTries emits the code that leaves the enclosing try blocks down to the
+// given depth, innermost first. Each finally block is compiled as if it
+// were outside of its own try, so that a return inside it doesn't run it
+// again.
+func (c *Compiler) leaveTries(depth int) error {
+	tries := c.scopes[c.scopeIndex].tries
+	defer func() { c.scopes[c.scopeIndex].tries = tries }()
+
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.scopes[c.scopeIndex].tries = tries[:i]
+		if tries[i].handler {
+			c.emit(code.OpPopHandler)
+		}
+		if err := c.compileFinally(tries[i].finally); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileAssign(node *ast.AssignExpression) error {
+	// "+=" applies "+" to the current value, and so on
+	operator := strings.TrimSuffix(node.Operator, "=")
+	op, ok := infixOperators[operator]
+	if operator != "" && !ok {
+		return fmt.Errorf("unknown operator %s", node.Operator)
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		if operator != "" {
+			if err := c.Compile(target); err != nil {
+				return err
+			}
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if operator != "" {
+			c.emit(op)
+		}
+
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok || symbol.Scope == BuiltinScope {
+			symbol = c.symbolTable.DefineImplicit(target.Value)
+		}
+		c.assignSymbol(symbol)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if operator != "" {
+			c.emit(code.OpDup2)
+			c.emit(code.OpIndex)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if operator != "" {
+			c.emit(op)
+		}
+		c.emit(code.OpSetIndex)
+
+	default:
+		return fmt.Errorf("cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+// compileFunction compiles a function literal. All the names its body
+// declares get a local slot up front, since blocks don't open scopes and
+// a closure may refer to a local declared after it. Locals that a nested
+// function uses are kept in cells.
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	captured := capturedNames(node.Body)
+	define := func(name string) {
+		if captured[name] {
+			c.symbolTable.DefineCell(name)
+		} else {
+			c.symbolTable.Define(name)
+		}
+	}
+	for _, p := range node.Parameters {
+		define(p.Value)
+	}
+	for _, name := range declaredNames(node.Body) {
+		define(name)
+	}
+	if c.symbolTable.numDefinitions > maxLocals {
+		name := node.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		return fmt.Errorf("too many local variables in function %s", name)
+	}
+
+	for _, name := range c.symbolTable.names {
+		if symbol := c.symbolTable.store[name]; symbol.Cell {
+			c.emit(code.OpMakeCell, symbol.Index)
+		}
+	}
+
+	if err := c.compileBlockValue(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	localNames := c.symbolTable.Names()
+	scope := c.leaveScope()
+
+	freeNames := make([]string, len(freeSymbols))
+	for i, s := range freeSymbols {
+		freeNames[i] = s.Name
+		switch s.Scope {
+		case LocalScope:
+			// The slot of a captured local holds its cell
+			c.emit(code.OpGetLocal, s.Index)
+		case FreeScope:
+			c.emit(code.OpGetFreeCell, s.Index)
+		}
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  scope.instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Name:          node.Name,
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+		SourceMap:     scope.sourceMap,
+		CallNames:     scope.callNames,
+		Literal:       node,
+	}
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		if s.Cell {
+			c.emit(code.OpGetCell, s.Index)
+		} else {
+			c.emit(code.OpGetLocal, s.Index)
+		}
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpConstant, c.builtinConstant(s.Name))
+	}
+}
+
+// define binds name, declared in the current scope, to the value on top of
+// the stack.
+func (c *Compiler) define(name string) {
+	s := c.symbolTable.Define(name)
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case s.Cell:
+		c.emit(code.OpSetCell, s.Index)
+	default:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) assignSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpAssignGlobal, s.Index)
+	case LocalScope:
+		if s.Cell {
+			c.emit(code.OpAssignCell, s.Index)
+		} else {
+			c.emit(code.OpAssignLocal, s.Index)
+		}
+	case FreeScope:
+		c.emit(code.OpAssignFree, s.Index)
+	}
+}
+
+func (c *Compiler) builtinConstant(name string) int {
+	if idx, ok := c.builtins[name]; ok {
+		return idx
+	}
+	builtin, _ := evaluator.LookupBuiltin(name)
+	idx := c.addConstant(builtin)
+	c.builtins[name] = idx
+	return idx
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.scopes[c.scopeIndex].sourceMap.Add(pos, c.pos)
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operands ...int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.checkOperands(op, operands)
+	newInstruction := code.Make(op, operands...)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+// operandLimits says what a program has too much of when the first operand
+// of an instruction doesn't fit in it.
+var operandLimits = map[code.Opcode]string{
+	code.OpConstant:      "too many constants",
+	code.OpClosure:       "too many constants",
+	code.OpImport:        "too many constants",
+	code.OpMember:        "too many constants",
+	code.OpJump:          "jump target out of range",
+	code.OpJumpNotTruthy: "jump target out of range",
+	code.OpIterNext:      "jump target out of range",
+	code.OpPushHandler:   "jump target out of range",
+	code.OpGetGlobal:     "too many globals",
+	code.OpSetGlobal:     "too many globals",
+	code.OpAssignGlobal:  "too many globals",
+	code.OpArray:         "too many elements in array literal",
+	code.OpHash:          "too many pairs in hash literal",
+	code.OpTemplate:      "too many parts in template literal",
+	code.OpCall:          "too many arguments in call",
+}
+
+// checkOperands records an error when an operand of op doesn't fit in the
+// bytes the instruction has for it, as the program is too big for the VM.
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	def, err := code.Lookup(byte(op))
+	if err != nil || c.err != nil {
+		return
+	}
+
+	for i, o := range operands {
+		if o >= 0 && o < 1<<(8*def.OperandWidths[i]) {
+			continue
+		}
+		if limit, ok := operandLimits[op]; ok && i == 0 {
+			c.err = errors.New(limit)
+		} else {
+			c.err = fmt.Errorf("operand %d of %s out of range: %d", i+1, def.Name, o)
+		}
+		return
+	}
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions: code.Instructions{},
+		sourceMap:    &code.SourceMap{},
+		callNames:    map[int]string{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() CompilationScope {
+	scope := c.scopes[c.scopeIndex]
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope
+}
+
+// Bytecode is a compiled program: the instructions of its top level, and
+// the constants and globals it shares with the functions it defines.
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	SourceMap    *code.SourceMap
+	CallNames    map[int]string
+	GlobalNames  []string
+	LocalNames   []string // local slots of the top level, used by its blocks
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	global := c.symbolTable
+	for global.Outer != nil {
+		global = global.Outer
+	}
+
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		CallNames:    c.scopes[c.scopeIndex].callNames,
+		GlobalNames:  global.Names(),
+		LocalNames:   global.MainLocals(),
+	}
+}
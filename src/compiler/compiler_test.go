@@ -0,0 +1,430 @@
+package compiler
+
+import (
+	"fmt"
+	"gorilla/ast"
+	"gorilla/code"
+	"gorilla/evaluator"
+	"gorilla/lexer"
+	"gorilla/object"
+	"gorilla/parser"
+	"strings"
+	"testing"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "1; 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "-1 <= 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessEqual),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let one = 1; let two = one;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input:             "let x = 1; x += 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 } else { 20 }",
+			expectedConstants: []interface{}{10, 20},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 13),
+				// 0010
+				code.Make(code.OpConstant, 1),
+				// 0013
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpMakeCell, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestBuiltins(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "len([])",
+			expectedConstants: []interface{}{"len"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTemplateLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `"a ${1} b"`,
+			expectedConstants: []interface{}{&object.String{Value: "a "}, 1, &object.String{Value: " b"}},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpTemplate, 3),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTooManyLocals(t *testing.T) {
+	var body strings.Builder
+	for i := 0; i <= maxLocals; i++ {
+		// Identifiers can't hold digits
+		fmt.Fprintf(&body, "let v%c%c = %d; ", 'a'+i/26, 'a'+i%26, i)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() { " + body.String() + "}", "too many local variables in function f"},
+		{"fn() { " + body.String() + "}", "too many local variables in function <anonymous>"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		if err == nil {
+			t.Errorf("no compiler error for %.20q", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong compiler error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	// Identifiers can't hold digits
+	name := func(i int) string {
+		return fmt.Sprintf("v%c%c%c%c", 'a'+i/26/26/26%26, 'a'+i/26/26%26, 'a'+i/26%26, 'a'+i%26)
+	}
+	repeat := func(n int, stmt func(i int) string) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteString(stmt(i))
+		}
+		return b.String()
+	}
+	assign := func(i int) string { return fmt.Sprintf("x = %d; ", i) }
+	let := func(i int) string { return fmt.Sprintf("let %s = true; ", name(i)) }
+	get := func(i int) string { return "x; " }
+
+	// Each of n statements x; in the block takes 4 bytes, and the jump
+	// over the else branch goes to 13 + 4n
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 0; " + repeat(1<<16-1, assign), ""},
+		{"let x = 0; " + repeat(1<<16, assign), "too many constants"},
+		{repeat(1<<16, let), ""},
+		{repeat(1<<16+1, let), "too many globals"},
+		{"let x = 1; if (true) { " + repeat(16380, get) + "}", ""},
+		{"let x = 1; if (true) { " + repeat(16381, get) + "}", "jump target out of range"},
+		{"[" + strings.Repeat("true, ", 1<<16) + "true]", "too many elements in array literal"},
+		{"len(" + strings.Repeat("true, ", 255) + "true)", "too many arguments in call"},
+	}
+
+	for i, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		switch {
+		case tt.expected == "" && err != nil:
+			t.Errorf("tests[%d] failed to compile: %s", i, err)
+		case tt.expected != "" && err == nil:
+			t.Errorf("tests[%d] compiled, want error %q", i, tt.expected)
+		case tt.expected != "" && err.Error() != tt.expected:
+			t.Errorf("tests[%d] wrong compiler error. want=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("wrong symbol for a. got=%+v", a)
+	}
+	if again := global.Define("a"); again != a {
+		t.Errorf("defining a twice gave a new symbol. got=%+v", again)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	b := local.DefineCell("b")
+	if b != (Symbol{Name: "b", Scope: LocalScope, Index: 0, Cell: true}) {
+		t.Errorf("wrong symbol for b. got=%+v", b)
+	}
+
+	nested := NewEnclosedSymbolTable(local)
+	c := nested.Define("c")
+
+	expected := []struct {
+		name   string
+		symbol Symbol
+	}{
+		{"a", a},
+		{"b", Symbol{Name: "b", Scope: FreeScope, Index: 0}},
+		{"c", c},
+		{"len", Symbol{Name: "len", Scope: BuiltinScope}},
+	}
+	for _, tt := range expected {
+		symbol, ok := nested.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if symbol != tt.symbol {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.symbol, symbol)
+		}
+	}
+
+	if len(nested.FreeSymbols) != 1 || nested.FreeSymbols[0] != b {
+		t.Errorf("wrong free symbols. got=%+v", nested.FreeSymbols)
+	}
+
+	if _, ok := nested.Resolve("undefined"); ok {
+		t.Errorf("undefined name resolved")
+	}
+
+	// A global used before it is defined gets a slot that its definition,
+	// in this program or a later one, fills
+	implicit := local.DefineImplicit("later")
+	if symbol, ok := nested.Resolve("later"); !ok || symbol != implicit {
+		t.Errorf("implicit global not resolved to its slot. got=%+v", symbol)
+	}
+	if defined := global.Define("later"); defined != implicit {
+		t.Errorf("definition got a new slot. want=%+v, got=%+v", implicit, defined)
+	}
+}
+
+func TestBlockSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+
+	// A block at the top level hides the global with local slots of the
+	// main program
+	block := NewBlockSymbolTable(global)
+	inner := block.Define("a")
+	if inner != (Symbol{Name: "a", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong symbol for a in block. got=%+v", inner)
+	}
+	if symbol, _ := block.Resolve("a"); symbol != inner {
+		t.Errorf("a in block resolved to %+v", symbol)
+	}
+	if symbol, _ := global.Resolve("a"); symbol != a {
+		t.Errorf("a out of block resolved to %+v", symbol)
+	}
+	if names := global.MainLocals(); len(names) != 1 || names[0] != "a" {
+		t.Errorf("wrong main locals. got=%v", names)
+	}
+
+	// A block in a function takes the next local slot of the function, and
+	// a closure in the block captures it
+	local := NewEnclosedSymbolTable(global)
+	local.Define("x")
+	block = NewBlockSymbolTable(local)
+	y := block.Define("y")
+	if y != (Symbol{Name: "y", Scope: LocalScope, Index: 1}) {
+		t.Errorf("wrong symbol for y in block. got=%+v", y)
+	}
+	if names := local.Names(); len(names) != 2 || names[1] != "y" {
+		t.Errorf("wrong local names. got=%v", names)
+	}
+
+	nested := NewEnclosedSymbolTable(block)
+	if symbol, _ := nested.Resolve("y"); symbol != (Symbol{Name: "y", Scope: FreeScope, Index: 0}) {
+		t.Errorf("y in closure resolved to %+v", symbol)
+	}
+	if symbol, _ := block.Resolve("x"); symbol.Scope != LocalScope || symbol.Index != 0 {
+		t.Errorf("x in block resolved to %+v", symbol)
+	}
+	if _, ok := local.Resolve("y"); ok {
+		t.Errorf("y resolved out of its block")
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		testInstructions(t, tt.input, tt.expectedInstructions, bytecode.Instructions)
+		testConstants(t, tt.input, tt.expectedConstants, bytecode.Constants)
+	}
+}
+
+func testInstructions(t *testing.T, input string, expected []code.Instructions, actual code.Instructions) {
+	t.Helper()
+
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if actual.String() != concatted.String() {
+		t.Errorf("wrong instructions for %q.\nwant=\n%s\ngot=\n%s",
+			input, concatted, actual)
+	}
+}
+
+func testConstants(t *testing.T, input string, expected []interface{}, actual []object.Object) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Errorf("wrong number of constants for %q. want=%d, got=%d",
+			input, len(expected), len(actual))
+		return
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				t.Errorf("constant %d is not Integer %d. got=%+v", i, constant, actual[i])
+			}
+		case string:
+			builtin, ok := actual[i].(*object.Builtin)
+			if !ok {
+				t.Errorf("constant %d is not Builtin %s. got=%T", i, constant, actual[i])
+			} else if want, _ := evaluator.LookupBuiltin(constant); builtin != want {
+				t.Errorf("constant %d is not Builtin %s", i, constant)
+			}
+		case *object.String:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant.Value {
+				t.Errorf("constant %d is not String %q. got=%+v", i, constant.Value, actual[i])
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				t.Errorf("constant %d is not CompiledFunction. got=%T", i, actual[i])
+				continue
+			}
+			testInstructions(t, input, constant, fn.Instructions)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package compiler
+
+import (
+	"gorilla/ast"
+	"gorilla/evaluator"
+)
+
+// walk calls visit for node and, as long as visit returns true, for each of
+// its children in source order.
+func walk(node ast.Node, visit func(ast.Node) bool) {
+	if !visit(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			walk(s, visit)
+		}
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			walk(s, visit)
+		}
+	case *ast.LetStatement:
+		walk(node.Name, visit)
+		walk(node.Value, visit)
+	case *ast.ReturnStatement:
+		walk(node.ReturnValue, visit)
+	case *ast.ExpressionStatement:
+		if node.Expression != nil {
+			walk(node.Expression, visit)
+		}
+	case *ast.ThrowStatement:
+		walk(node.Value, visit)
+	case *ast.ImportStatement:
+		if node.Alias != nil {
+			walk(node.Alias, visit)
+		}
+	case *ast.PrefixExpression:
+		walk(node.Right, visit)
+	case *ast.InfixExpression:
+		walk(node.Left, visit)
+		walk(node.Right, visit)
+	case *ast.IfExpression:
+		walk(node.Condition, visit)
+		walk(node.Consequence, visit)
+		if node.Alternative != nil {
+			walk(node.Alternative, visit)
+		}
+	case *ast.WhileExpression:
+		walk(node.Condition, visit)
+		walk(node.Body, visit)
+	case *ast.ForExpression:
+		if node.Key != nil {
+			walk(node.Key, visit)
+		}
+		walk(node.Value, visit)
+		walk(node.Iterable, visit)
+		walk(node.Body, visit)
+	case *ast.FunctionLiteral:
+		for _, p := range node.Parameters {
+			walk(p, visit)
+		}
+		walk(node.Body, visit)
+	case *ast.CallExpression:
+		walk(node.Function, visit)
+		for _, a := range node.Arguments {
+			walk(a, visit)
+		}
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			walk(e, visit)
+		}
+	case *ast.TemplateLiteral:
+		for _, part := range node.Parts {
+			walk(part, visit)
+		}
+	case *ast.IndexExpression:
+		walk(node.Left, visit)
+		walk(node.Index, visit)
+	case *ast.HashLiteral:
+		for _, key := range node.Keys {
+			walk(key, visit)
+			walk(node.Pairs[key], visit)
+		}
+	case *ast.TryExpression:
+		walk(node.Block, visit)
+		if node.CatchParam != nil {
+			walk(node.CatchParam, visit)
+		}
+		if node.Catch != nil {
+			walk(node.Catch, visit)
+		}
+		if node.Finally != nil {
+			walk(node.Finally, visit)
+		}
+	case *ast.AssignExpression:
+		walk(node.Target, visit)
+		walk(node.Value, visit)
+	case *ast.MemberExpression:
+		// The member is a name inside the module, not a variable
+		walk(node.Object, visit)
+	}
+}
+
+// declaredNames returns the names a program or function body binds in its
+// own scope: let statements, loop variables and imports. Blocks don't open
+// a scope of their own, function literals and catch blocks do and are
+// skipped.
+func declaredNames(body ast.Node) []string {
+	var names []string
+	seen := map[string]bool{}
+	declare := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	var visit func(ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			declare(node.Name.Value)
+		case *ast.ForExpression:
+			if node.Key != nil {
+				declare(node.Key.Value)
+			}
+			declare(node.Value.Value)
+		case *ast.TryExpression:
+			walk(node.Block, visit)
+			if node.Finally != nil {
+				walk(node.Finally, visit)
+			}
+			return false
+		case *ast.ImportStatement:
+			declare(evaluator.ImportName(node))
+		}
+		return true
+	}
+	walk(body, visit)
+
+	return names
+}
+
+// capturedNames returns every name used inside the function literals nested
+// in body. A local of body with one of these names may be captured by a
+// closure.
+func capturedNames(body ast.Node) map[string]bool {
+	names := map[string]bool{}
+
+	walk(body, func(node ast.Node) bool {
+		fn, ok := node.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+		walk(fn.Body, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Identifier); ok {
+				names[ident.Value] = true
+			}
+			return true
+		})
+		return false
+	})
+
+	return names
+}
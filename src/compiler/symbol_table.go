@@ -0,0 +1,182 @@
+package compiler
+
+import "gorilla/evaluator"
+
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	BuiltinScope SymbolScope = "BUILTIN"
+	FreeScope    SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Cell  bool // a local that a closure captures
+}
+
+// SymbolTable maps the names of one scope, the program or a function, to
+// the slots their values are stored in.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	names          []string // names of the slots, by index
+	numDefinitions int
+
+	// FreeSymbols are the symbols of enclosing functions this scope uses,
+	// as resolved in the enclosing scope.
+	FreeSymbols []Symbol
+
+	// implicit holds globals that were used before anything defined them.
+	// They get a slot so that a later definition, possibly in a later
+	// REPL line, is found at run time.
+	implicit map[string]bool
+
+	// block marks the scope of a block with names of its own, like a catch
+	// block, which takes its slots from the enclosing function.
+	block bool
+
+	// mainLocals names the local slots of the main program, which only
+	// blocks at the top level use.
+	mainLocals []string
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store:    make(map[string]Symbol),
+		implicit: make(map[string]bool),
+	}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// NewBlockSymbolTable returns the scope of a block inside outer. The names
+// it defines hide the ones of outer until the block ends, and get local
+// slots of the enclosing function, or of the main program at the top level.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
+// Define binds name in this scope. Defining a name twice returns the slot
+// it already has.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && symbol.Scope != FreeScope {
+		delete(s.implicit, name)
+		return symbol
+	}
+
+	var symbol Symbol
+	switch {
+	case s.block:
+		symbol = s.Outer.blockSlot(name)
+	case s.Outer == nil:
+		symbol = Symbol{Name: name, Scope: GlobalScope, Index: s.numDefinitions}
+		s.names = append(s.names, name)
+		s.numDefinitions++
+	default:
+		symbol = Symbol{Name: name, Scope: LocalScope, Index: s.numDefinitions}
+		s.names = append(s.names, name)
+		s.numDefinitions++
+	}
+
+	s.store[name] = symbol
+	return symbol
+}
+
+// blockSlot returns a new local slot for name, defined by a block inside
+// this scope.
+func (s *SymbolTable) blockSlot(name string) Symbol {
+	switch {
+	case s.block:
+		return s.Outer.blockSlot(name)
+	case s.Outer == nil:
+		s.mainLocals = append(s.mainLocals, name)
+		return Symbol{Name: name, Scope: LocalScope, Index: len(s.mainLocals) - 1}
+	default:
+		s.names = append(s.names, name)
+		s.numDefinitions++
+		return Symbol{Name: name, Scope: LocalScope, Index: s.numDefinitions - 1}
+	}
+}
+
+// DefineCell binds name in this scope as a local that closures capture.
+func (s *SymbolTable) DefineCell(name string) Symbol {
+	symbol := s.Define(name)
+	if symbol.Scope == LocalScope {
+		symbol.Cell = true
+		s.store[name] = symbol
+	}
+	return symbol
+}
+
+// DefineImplicit gives name a global slot without defining it.
+func (s *SymbolTable) DefineImplicit(name string) Symbol {
+	global := s
+	for global.Outer != nil {
+		global = global.Outer
+	}
+
+	if symbol, ok := global.store[name]; ok {
+		return symbol
+	}
+	symbol := global.Define(name)
+	global.implicit[name] = true
+	return symbol
+}
+
+// Resolve looks name up in this scope and the enclosing ones. A builtin is
+// found when no global of the same name has been defined.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok && !s.implicit[name] {
+		return symbol, true
+	}
+
+	if s.Outer == nil {
+		if _, isBuiltin := evaluator.LookupBuiltin(name); isBuiltin {
+			return Symbol{Name: name, Scope: BuiltinScope}, true
+		}
+		return symbol, ok
+	}
+
+	// A block shares the slots of the function it is in
+	symbol, ok = s.Outer.Resolve(name)
+	if s.block || !ok || symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		return symbol, ok
+	}
+
+	return s.defineFree(symbol), true
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// MainLocals returns the names of the local slots of the main program, by
+// index.
+func (s *SymbolTable) MainLocals() []string {
+	names := make([]string, len(s.mainLocals))
+	copy(names, s.mainLocals)
+	return names
+}
+
+// Names returns the names of the slots of this scope, by index.
+func (s *SymbolTable) Names() []string {
+	names := make([]string, len(s.names))
+	copy(names, s.names)
+	return names
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"gorilla/ast"
+	"gorilla/compiler"
+	"gorilla/evaluator"
+	"gorilla/object"
+	"gorilla/repl"
+	"gorilla/vm"
+	"path/filepath"
+)
+
+// newEngine returns the engine opts ask for. The REPL keeps one for the
+// whole session, so every line sees the bindings of the earlier ones.
+func newEngine(opts *options) (repl.Engine, error) {
+	newSession := func() *object.Session {
+		session := object.NewSession()
+		session.SearchPath = filepath.SplitList(opts.path)
+		session.MaxDepth = opts.maxDepth
+		return session
+	}
+
+	var e repl.Engine
+	switch opts.engine {
+	case "eval":
+		e = &evalEngine{newSession: newSession}
+	case "vm":
+		e = &vmEngine{newSession: newSession}
+	default:
+		return nil, fmt.Errorf("unknown engine %q, want eval or vm", opts.engine)
+	}
+	e.Reset()
+	return e, nil
+}
+
+// evalEngine walks the syntax tree.
+type evalEngine struct {
+	env        *object.Environment
+	newSession func() *object.Session
+}
+
+func (e *evalEngine) Run(program *ast.Program) object.Object {
+	return evaluator.Eval(program, e.env)
+}
+
+func (e *evalEngine) Names() []string {
+	return e.env.Names()
+}
+
+func (e *evalEngine) Get(name string) (object.Object, bool) {
+	return e.env.Get(name)
+}
+
+func (e *evalEngine) Define(name string, val object.Object) {
+	e.env.Set(name, val)
+}
+
+// Reset starts a new session, which forgets the imported modules too.
+func (e *evalEngine) Reset() {
+	e.env = object.NewSessionEnvironment(e.newSession())
+}
+
+// vmEngine compiles to bytecode and runs it on the virtual machine.
+type vmEngine struct {
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     *vm.Globals
+	newSession  func() *object.Session
+}
+
+func (e *vmEngine) Run(program *ast.Program) object.Object {
+	comp := compiler.NewWithState(e.symbolTable, e.constants)
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: "compiler error: " + err.Error()}
+	}
+
+	bytecode := comp.Bytecode()
+	e.constants = bytecode.Constants
+	return vm.NewWithGlobals(bytecode, e.globals).Run()
+}
+
+func (e *vmEngine) Names() []string {
+	var names []string
+	for i, name := range e.symbolTable.Names() {
+		if e.globals.Values[i] != nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (e *vmEngine) Get(name string) (object.Object, bool) {
+	for i, n := range e.symbolTable.Names() {
+		if n == name && e.globals.Values[i] != nil {
+			return e.globals.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+func (e *vmEngine) Define(name string, val object.Object) {
+	e.globals.Values[e.symbolTable.Define(name).Index] = val
+}
+
+// Reset starts a new session, which forgets the imported modules too.
+func (e *vmEngine) Reset() {
+	e.symbolTable = compiler.NewSymbolTable()
+	e.constants = []object.Object{}
+	e.globals = vm.NewGlobals()
+	e.globals.Session = e.newSession()
+}
@@ -0,0 +1,364 @@
+package enginetest
+
+import (
+	"gorilla/evaluator"
+	"gorilla/object"
+	"testing"
+)
+
+func testArrayLiterals(t *testing.T, e Engine) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := e.eval(input)
+	result, ok := evaluated.(*object.Array)
+
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d",
+			len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func testArrayIndexExpressions(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"[1, 2, 3][0]",
+			1,
+		},
+		{
+			"[1, 2, 3][1]",
+			2,
+		},
+		{
+			"[1, 2, 3][2]",
+			3,
+		},
+		{
+			"let i = 0; [1][i];",
+			1,
+		},
+		{
+			"[1, 2, 3][1 + 1];",
+			3,
+		},
+		{
+			"let myArray = [1, 2, 3]; myArray[2];",
+			3,
+		},
+		{
+			"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
+			6,
+		},
+		{
+			"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
+			2,
+		},
+		{
+			"[1, 2, 3][3]",
+			nil,
+		},
+		{
+			"[1, 2, 3][-1]",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testHashLiterals(t *testing.T, e Engine) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := e.eval(input)
+	result, ok := evaluated.(*object.Hash)
+
+	if !ok {
+		t.Fatalf("engine didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{evaluator.TRUE, 5},
+		{evaluator.FALSE, 6},
+	}
+
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
+	}
+
+	for _, tt := range expected {
+		value, ok := result.Get(tt.key)
+		if !ok {
+			t.Errorf("no pair for key %s", tt.key.Inspect())
+			continue
+		}
+		testIntegerObject(t, value, tt.value)
+	}
+}
+
+func testHashIndexExpressions(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			`{"foo": 5}["foo"]`,
+			5,
+		},
+		{
+			`{"foo": 5}["bar"]`,
+			nil,
+		},
+		{
+			`let key = "foo"; {"foo": 5}[key]`,
+			5,
+		},
+		{
+			`{}["foo"]`,
+			nil,
+		},
+		{
+			`{5: 5}[5]`,
+			5,
+		},
+		{
+			`{true: 5}[true]`,
+			5,
+		},
+		{
+			`{false: 5}[false]`,
+			5,
+		},
+		{
+			`{0.0: 5}[-0.0]`,
+			5,
+		},
+		{
+			`let h = {-0.0: 5}; h[0.0] += 1; len(h) * 10 + h[0.0]`,
+			16,
+		},
+		{
+			`{1.0: 5}[1]`,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testArrayBuiltins(t *testing.T, e Engine) {
+	testInspect(t, e, []inspectTest{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`map([1, [2, 3]], len)`, "argument to `len` not supported, got INTEGER"},
+		{`map(["a", "bc"], len)`, "[1, 2]"},
+		{`let n = 10; map([1], fn(x) { x + n })`, "[11]"},
+		{`map([[1], [2]], fn(xs) { map(xs, fn(x) { -x }) })`, "[[-1], [-2]]"},
+		{`map([1], fn(a, b) { a })`, "wrong number of arguments: want=2, got=1"},
+		{`map([1], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`map(1, len)`, "argument 1 to `map` must be ARRAY, got INTEGER"},
+		{`map([1], 2)`, "argument 2 to `map` must be a function, got INTEGER"},
+		{`map([1])`, "wrong number of arguments. got=1, want=2"},
+		{`filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })`, "[2, 4]"},
+		{`filter([], fn(x) { true })`, "[]"},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x })`, "6"},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, "16"},
+		{`reduce(["a", "b"], fn(acc, x) { x + acc }, "")`, "ba"},
+		{`reduce([], fn(acc, x) { acc })`, "`reduce` of an empty array needs an initial value"},
+		{`let total = 0; each([1, 2], fn(x) { total += x }); total`, "3"},
+		{`each([1], fn(x) { x })`, "null"},
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`let xs = [2, 1]; sort(xs); xs`, "[2, 1]"},
+		{`sort([1, 3, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort([[1, "b"], [0, "a"], [1, "a"]], fn(a, b) { a[0] < b[0] })`, "[[0, a], [1, b], [1, a]]"},
+		{`sort([1, "a"])`, "type mismatch: STRING < INTEGER"},
+		{`sort([1, 2], fn(a, b) { 1 })`, "`sort` comparator must return BOOLEAN, got INTEGER"},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`slice([1, 2, 3, 4], 1, 3)`, "[2, 3]"},
+		{`slice([1, 2, 3], 1)`, "[2, 3]"},
+		{`slice([1, 2, 3], 2, 1)`, "[]"},
+		{`slice([1, 2, 3], 5)`, "[]"},
+		{`slice([1], -1)`, "`slice` start must not be negative, got -1"},
+		{`concat([1], [], [2, 3])`, "[1, 2, 3]"},
+		{`concat()`, "[]"},
+		{`concat([1], 2)`, "argument 2 to `concat` must be ARRAY, got INTEGER"},
+		{`contains([1, "a", true], "a")`, "true"},
+		{`contains([1, 2], 3)`, "false"},
+		{`contains(1, 1)`, "argument 1 to `contains` must be STRING or ARRAY, got INTEGER"},
+		{`indexOf([1, 2, 3], 3)`, "2"},
+		{`indexOf([1, 2, 3], 4)`, "-1"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`zip([1], [2], [3])`, "[[1, 2, 3]]"},
+		{`zip()`, "wrong number of arguments. got=0, want=1 or more"},
+		{`flatten([1, [2, [3]], []])`, "[1, 2, [3]]"},
+		{`unique([1, 2, 1, "a", "a", 2.0])`, "[1, 2, a]"},
+	})
+}
+
+func testHashBuiltins(t *testing.T, e Engine) {
+	testInspect(t, e, []inspectTest{
+		{`keys({"a": 1, 2: "b", true: 3})`, "[a, 2, true]"},
+		{`values({"a": 1, 2: "b"})`, "[1, b]"},
+		{`entries({"a": 1, 2: "b"})`, "[[a, 1], [2, b]]"},
+		{`keys({})`, "[]"},
+		{`keys([])`, "argument to `keys` must be HASH, got ARRAY"},
+		{`entries(fromEntries([["a", 1], [2, "b"], ["a", 3]]))`, "[[a, 3], [2, b]]"},
+		{`fromEntries(entries({"x": [1]}))["x"]`, "[1]"},
+		{`fromEntries([["a"]])`, "entry to `fromEntries` must be a [key, value] ARRAY, got [a]"},
+		{`fromEntries([[[1], 2]])`, "unusable as hash key: ARRAY"},
+		{`has({"a": if (false) { 1 }}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({}, {})`, "unusable as hash key: HASH"},
+		{`get({"a": 1}, "a", 0)`, "1"},
+		{`get({"a": 1}, "b", 0)`, "0"},
+		{`get({"a": 1}, "b")`, "null"},
+		{`let h = {"a": 1, "b": 2}; [keys(delete(h, "a")), keys(h)]`, "[[b], [a, b]]"},
+		{`entries(delete({"a": 1}, "z"))`, "[[a, 1]]"},
+		{`let h = {"a": 1, "b": 2}; deleteInPlace(h, "a"); [keys(h), len(h), h["a"]]`, "[[b], 1, null]"},
+		{`let h = {"a": 1}; deleteInPlace(h, "a"); h["a"] = 2; keys(h)`, "[a]"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; let seen = []; for (k in h) { deleteInPlace(h, "b"); seen = push(seen, k) }; seen`, "[a, c]"},
+		{`entries(merge({"a": 1, "b": 2}, {"b": 3}, {"c": 4}))`, "[[a, 1], [b, 3], [c, 4]]"},
+		{`let h = {"a": 1}; merge(h, {"b": 2}); keys(h)`, "[a]"},
+		{`let h = {"a": 1}; mergeInPlace(h, {"a": 0, "b": 2}); entries(h)`, "[[a, 0], [b, 2]]"},
+		{`merge()`, "wrong number of arguments. got=0, want=1 or more"},
+		{`merge({}, [])`, "argument 2 to `merge` must be HASH, got ARRAY"},
+	})
+}
+
+func testHashOrder(t *testing.T, e Engine) {
+	testInspect(t, e, []inspectTest{
+		{`{"b": 1, "a": 2, 3: 4, true: 5}`, "{b: 1, a: 2, 3: 4, true: 5}"},
+		{`let h = {"x": 1, "y": 2}; h["x"] = 3; h["z"] = 4; h`, "{x: 3, y: 2, z: 4}"},
+		{`let h = {"a": 1, "b": 2}; deleteInPlace(h, "a"); h["a"] = 3; h`, "{b: 2, a: 3}"},
+		{`string({"k": [1, {"n": true, "m": false}]})`, "{k: [1, {n: true, m: false}]}"},
+		{`let h = {}; for (i in range(12)) { h[11 - i] = i }; join(keys(h), ",")`, "11,10,9,8,7,6,5,4,3,2,1,0"},
+		{`let out = ""; for (k, v in {"z": 1, "y": 2, "x": 3}) { out += k }; out`, "zyx"},
+		{`values(merge({"q": 1, "p": 2}, {"o": 3, "q": 4}))`, "[4, 2, 3]"},
+	})
+}
+
+func testEquality(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[1, [2, [3]]] == [1.0, [2, [3.0]]]", true},
+		{"[] == []", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} != {"a": 1, "b": 2}`, true},
+		{`{1: "a"} == {1.0: "a"}`, false},
+		{"[] == {}", false},
+		{"[1] == 1", false},
+		{`[float("nan")] == [float("nan")]`, false},
+		{"range(3) == range(0, 3, 1)", true},
+		{"let f = fn() { 1 }; f == f", true},
+		{"fn() { 1 } == fn() { 1 }", false},
+		{"let a = [1, 2]; a[1] = a; let b = [1, 2]; b[1] = b; a == b", true},
+		{"let a = [1, 2]; a[1] = a; let b = [0, 2]; b[1] = b; a == b", false},
+		{`let h = {"x": 1}; h["h"] = h; let g = {"x": 1}; g["h"] = g; h == g`, true},
+		{`let h = {}; h["h"] = h; let g = {}; g["h"] = h; h == g`, true},
+		{"let a = [1]; same(a, a)", true},
+		{"same([1], [1])", false},
+		{`same({}, {})`, false},
+		{"same(1, 1)", true},
+		{"same(1, 1.0)", false},
+		{`same(float("nan"), float("nan"))`, true},
+		{`same("a", "a")`, true},
+		{"same(true, 1 < 2)", true},
+		{"let f = fn() { 1 }; same(f, f)", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		if !testBooleanObject(t, evaluated, tt.expected) {
+			t.Errorf("wrong result for %q", tt.input)
+		}
+	}
+}
+
+func testFrozenKeys(t *testing.T, e Engine) {
+	testInspect(t, e, []inspectTest{
+		{"let h = {}; h[freeze([1, 2])] = 3; h[freeze([1, 2])]", "3"},
+		{"let h = {freeze([1, 2]): 3}; h[freeze([2, 1])]", "null"},
+		{`let h = {}; for (x in range(2)) { for (y in range(2)) { h[freeze([x, y])] = x * 10 + y } }; h`,
+			"{[0, 0]: 0, [0, 1]: 1, [1, 0]: 10, [1, 1]: 11}"},
+		{`let h = {freeze({"a": 1, "b": [2]}): "x"}; h[freeze({"b": [2], "a": 1})]`, "x"},
+		{`let h = {freeze([1]): "int", freeze([1.0]): "float"}; [h[freeze([1])], h[freeze([1.0])]]`, "[int, float]"},
+		{`let h = {freeze([[1], "a"]): 1}; [has(h, freeze([[1], "a"])), has(h, freeze([[1], "b"]))]`, "[true, false]"},
+		{"let f = fn() { 1 }; let h = {freeze([f]): 1}; [get(h, freeze([f]), 0), get(h, freeze([fn() { 1 }]), 0)]", "[1, 0]"},
+		{"let a = [1, 2]; let f = freeze(a); a[0] = 9; [a, f]", "[[9, 2], [1, 2]]"},
+		{`let f = freeze([[1], {"a": [2]}]); [isFrozen(f), isFrozen(f[0]), isFrozen(f[1]), isFrozen(f[1]["a"])]`,
+			"[true, true, true, true]"},
+		{"[isFrozen([1]), isFrozen({}), isFrozen(1), freeze(1), freeze([1]) == [1]]", "[false, false, false, 1, true]"},
+		{"let f = freeze([1]); same(freeze(f), f)", "true"},
+		{`[isFrozen(reverse(freeze([1]))), isFrozen(delete(freeze({"a": 1}), "a"))]`, "[false, false]"},
+		{"{[1]: 2}", "unusable as hash key: ARRAY"},
+		{"let h = {}; h[{}] = 1", "unusable as hash key: HASH"},
+		{"let f = freeze([1]); f[0] = 2", "cannot change a frozen ARRAY"},
+		{"let f = freeze([1]); f[0] += 2", "cannot change a frozen ARRAY"},
+		{`let f = freeze({}); f["a"] = 1`, "cannot change a frozen HASH"},
+		{`deleteInPlace(freeze({"a": 1}), "a")`, "cannot change a frozen HASH"},
+		{`mergeInPlace(freeze({}), {"a": 1})`, "cannot change a frozen HASH"},
+		{"let a = [1]; a[0] = a; freeze(a)", "cannot freeze a value that contains itself"},
+		{"let a = [1]; freeze([a, a])", "[[1], [1]]"},
+		{"freeze()", "wrong number of arguments. got=0, want=1"},
+	})
+}
+
+func testCyclicValues(t *testing.T, e Engine) {
+	testInspect(t, e, []inspectTest{
+		{"let a = [1]; a[0] = a; a", "[[...]]"},
+		{`let h = {"a": 1}; h["self"] = h; h`, "{a: 1, self: {...}}"},
+		{`let a = [0]; let h = {"a": a}; a[0] = h; a`, "[{a: [...]}]"},
+		{`let a = [1, 2]; a[1] = a; "${a}!"`, "[1, [...]]!"},
+		{"let a = [1]; a[0] = a; string(a)", "[[...]]"},
+		{"let a = [1]; a[0] = a; len(a[0][0][0])", "1"},
+		{"let b = [1]; [b, b]", "[[1], [1]]"},
+		{"let a = [1]; a[0] = a; let b = [1]; b[0] = b; [a == b, a == a, a == [a]]", "[true, true, true]"},
+		{"let a = [1]; a[0] = a; freeze(a)", "cannot freeze a value that contains itself"},
+	})
+}
@@ -0,0 +1,180 @@
+package enginetest
+
+import (
+	"gorilla/object"
+	"testing"
+)
+
+func testWhileExpressions(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i=0; while (i<10) { let i=i+1 }; i", 10},
+		{"while (false) { 10 }", nil},
+		{"let i=0; while (false) { 10 }; i", 0},
+		{"while (1 > 2) { 10 }", nil},
+		{`let i=0;
+		while (i < 10) {
+			let i=i+1;
+		}`, nil},
+		{"let i = 0; while (true) { i += 1; if (i == 5) { break } }; i", 5},
+		{"let i = 0; while (true) { i += 1; break; i = 100 }; i", 1},
+		{`let i = 0; let sum = 0;
+		while (i < 10) {
+			i += 1;
+			if (i % 2 == 0) { continue }
+			sum += i;
+		};
+		sum`, 25},
+		{`let i = 0; let count = 0;
+		while (i < 3) {
+			i += 1;
+			let j = 0;
+			while (true) { j += 1; if (j > 2) { break } count += 1 }
+		};
+		count`, 6},
+		{"let f = fn() { while (true) { return 7 } }; f()", 7},
+		{"let i = 0; while (i < 3) { i += 1; continue }", nil},
+		{"let i = 0; while (true) { try { break } finally { i = 9 } }; i", 9},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testForExpressions(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x }; sum", 6},
+		{"let sum = 0; for (i, x in [10, 20, 30]) { sum += i * x }; sum", 80},
+		{`let s = ""; for (k in {"a": 1, "b": 2, "c": 3}) { s += k }; s`, "abc"},
+		{`let s = ""; for (k, v in {"x": 1, "y": 2}) { s += k + string(v) }; s`, "x1y2"},
+		{`let h = {"z": 1, "a": 2}; h["m"] = 3; h["z"] = 4; let s = ""; for (k in h) { s += k }; s`, "zam"},
+		{`let s = ""; for (c in "abc") { s = c + s }; s`, "cba"},
+		{`let n = 0; for (i, c in "abc") { n += i }; n`, 3},
+		{`let s = ""; for (c in "añb") { s = c + s }; s`, "bña"},
+		{`let n = 0; for (i, c in "日本語") { n += i }; n`, 3},
+		{"let sum = 0; for (i in range(5)) { sum += i }; sum", 10},
+		{"let sum = 0; for (i in range(2, 5)) { sum += i }; sum", 9},
+		{"let sum = 0; for (i in range(10, 0, -3)) { sum += i }; sum", 22},
+		{"let n = 0; for (i in range(3, 3)) { n += 1 }; n", 0},
+		{"let last = 0; for (i in range(100)) { if (i == 7) { break } last = i }; last", 6},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue } sum += x }; sum", 4},
+		{"let f = fn() { for (x in [5, 6]) { return x } }; f()", 5},
+		{"let count = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { count += 1 } }; count", 6},
+		{"for (x in []) { x }", nil},
+		{"for (x in [1, 2]) { x }", 2},
+		{"len(range(0, 10, 3))", 4},
+		{"len(range(10, 0, -1))", 10},
+		{"len(range(-9223372036854775807, 9223372036854775807, 2))", 9223372036854775807},
+		{"len(range(0, 9223372036854775807, 9223372036854775807))", 1},
+		{"len(range(9223372036854775807, -9223372036854775807 - 1, -9223372036854775807 - 1))", 2},
+		{`let s = ""; for (i in range(9223372036854775806, 9223372036854775807)) { s += string(i) }; s`, "9223372036854775806"},
+		{`let s = ""; for (i in range(9223372036854775807, -9223372036854775807 - 1, -9223372036854775807 - 1)) { s += "${i} " }; s`,
+			"9223372036854775807 -1 "},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testTryExpressions(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { 1 + true; 1 } catch (e) { 2 }", 2},
+		{"try { throw 5; 1 } catch (e) { e }", 5},
+		{"try { throw 5 } catch { 3 }", 3},
+		{`try { throw "bad" } catch (e) { e }`, "bad"},
+		{`try { foobar } catch (e) { e["message"] }`, "identifier not found: foobar"},
+		{`try { foobar } catch (e) { e["line"] }`, 1},
+		{`try { foobar } catch (e) { e["column"] }`, 7},
+		{"let x = 0; try { 1 } finally { let x = 7 }; x", 7},
+		{"let x = 0; try { throw 1 } catch (e) { 2 } finally { let x = 7 }; x", 7},
+		{"let f = fn() { try { return 1 } finally { 2 } }; f()", 1},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"let f = fn() { try { throw 1 } finally { return 2 } }; f()", 2},
+		{"let f = fn() { throw 4 }; let g = fn() { f() + 1 }; try { g() } catch (e) { e * 10 }", 40},
+		{"try { try { throw 1 } catch (e) { throw e + 1 } } catch (e) { e }", 2},
+		{"try { try { throw 1 } finally { 9 } } catch (e) { e }", 1},
+		{"let e = 1; try { throw 2 } catch (e) { e }; e", 1},
+		{"let e = 1; try { throw 2 } catch (e) { e = 3 }; e", 1},
+		{"let x = 1; try { throw 2 } catch (e) { x = e }; x", 2},
+		{"let f = fn() { let e = 1; try { throw 2 } catch (e) { 0 }; e }; f()", 1},
+		{"let f = fn() { try { throw 2 } catch (e) { fn() { e } } }; f()()", 2},
+		{"let fs = []; for (i in [1, 2]) { try { throw i } catch (e) { fs = push(fs, fn() { e }) } }; fs[0]() + fs[1]() * 10", 21},
+		{"try { throw 1 } catch (e) { try { throw 2 } catch (e) { 0 }; e }", 1},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		}
+	}
+}
+
+func testUncaughtThrow(t *testing.T, e Engine) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`throw "oops"`, "oops"},
+		{"throw 1 + 2", "3"},
+		{`throw {"message": "bad input", "code": 2}`, "bad input"},
+		{"try { throw 1 } finally { 2 }", "1"},
+		{"try { 1 } catch (e) { 2 } finally { throw 3 }", "3"},
+		{"try { throw 1 } catch (e) { throw 2 }", "2"},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+		if errObj.Value == nil {
+			t.Errorf("thrown error has no value")
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package enginetest holds the tests of the Gorilla language that every
+// execution engine has to pass. The evaluator and the VM both run them
+// with Run, so a case added here checks both and they can't drift apart.
+package enginetest
+
+import (
+	"gorilla/ast"
+	"gorilla/evaluator"
+	"gorilla/lexer"
+	"gorilla/object"
+	"gorilla/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Engine runs program in session and returns the value of its last
+// statement, or the error that stopped it.
+type Engine func(program *ast.Program, session *object.Session) object.Object
+
+var tests = []struct {
+	name string
+	fn   func(t *testing.T, e Engine)
+}{
+	{"IntegerExpression", testIntegerExpression},
+	{"FloatExpression", testFloatExpression},
+	{"BooleanExpression", testBooleanExpression},
+	{"BangOperator", testBangOperator},
+	{"IfElseExpressions", testIfElseExpressions},
+	{"ReturnStatements", testReturnStatements},
+	{"LetStatements", testLetStatements},
+	{"FunctionApplication", testFunctionApplication},
+	{"Closures", testClosures},
+	{"AssignExpressions", testAssignExpressions},
+	{"StringLiteral", testStringLiteral},
+	{"StringConcatenation", testStringConcatenation},
+	{"TemplateLiterals", testTemplateLiterals},
+	{"StringIndexExpressions", testStringIndexExpressions},
+	{"BuiltinFunctions", testBuiltinFunctions},
+	{"ArrayLiterals", testArrayLiterals},
+	{"ArrayIndexExpressions", testArrayIndexExpressions},
+	{"HashLiterals", testHashLiterals},
+	{"HashIndexExpressions", testHashIndexExpressions},
+	{"WhileExpressions", testWhileExpressions},
+	{"ForExpressions", testForExpressions},
+	{"TryExpressions", testTryExpressions},
+	{"UncaughtThrow", testUncaughtThrow},
+	{"ErrorHandling", testErrorHandling},
+	{"CallErrors", testCallErrors},
+	{"AssignmentErrors", testAssignmentErrors},
+	{"ForErrors", testForErrors},
+	{"ErrorPositions", testErrorPositions},
+	{"ErrorStackTraces", testErrorStackTraces},
+	{"CallErrorTraces", testCallErrorTraces},
+	{"Imports", testImports},
+	{"ImportSearchPath", testImportSearchPath},
+	{"ImportSessions", testImportSessions},
+	{"ImportErrors", testImportErrors},
+	{"FrozenKeys", testFrozenKeys},
+	{"CyclicValues", testCyclicValues},
+	{"Equality", testEquality},
+	{"HashOrder", testHashOrder},
+	{"HashBuiltins", testHashBuiltins},
+	{"ArrayBuiltins", testArrayBuiltins},
+	{"StringBuiltins", testStringBuiltins},
+}
+
+// Run runs every test on engine, each as a subtest.
+func Run(t *testing.T, engine Engine) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { tt.fn(t, engine) })
+	}
+}
+
+// eval runs input in a fresh session.
+func (e Engine) eval(input string) object.Object {
+	return e.run(object.NewSession(), lexer.New(input))
+}
+
+// evalInDir writes files into a fresh directory and runs input in a fresh
+// session as if it was the file main.gor in that directory.
+func (e Engine) evalInDir(t *testing.T, files map[string]string, input string) object.Object {
+	return e.evalInSession(object.NewSession(), writeFiles(t, files), input)
+}
+
+// evalInSession runs input in session as if it was the file main.gor in
+// dir.
+func (e Engine) evalInSession(session *object.Session, dir, input string) object.Object {
+	return e.run(session, lexer.NewWithFile(input, filepath.Join(dir, "main.gor")))
+}
+
+func (e Engine) run(session *object.Session, l *lexer.Lexer) object.Object {
+	p := parser.New(l)
+	program := p.ParseProgram()
+	return e(program, session)
+}
+
+// writeFiles writes files into a fresh directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// inspectTest is a case whose result is checked by how it prints.
+type inspectTest struct {
+	input    string
+	expected string // the Inspect() of the result, or the error message
+}
+
+// testInspect runs every case on e and compares the Inspect() of its
+// result, or the message of the error it raises, to the expected string.
+func testInspect(t *testing.T, e Engine, tests []inspectTest) {
+	t.Helper()
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("%s wrong. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != evaluator.NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,345 @@
+package enginetest
+
+import (
+	"gorilla/object"
+	"testing"
+)
+
+func testErrorHandling(t *testing.T, e Engine) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			"5 + true;",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"5 + true; 5;",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`"sum: ${5 + true}"`,
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"-true",
+			"unknown operator: -BOOLEAN",
+		},
+		{
+			"true + false;",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"5; true + false; 5",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"if (10 > 1) { true + false; }",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			`
+	if (10 > 1) {
+	if (10 > 1) {
+	return true + false;
+	}
+	return 1;
+	}
+	`,
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"foobar",
+			"identifier not found: foobar",
+		},
+		{
+			`"Hello" - "World"`,
+			"unknown operator: STRING - STRING",
+		},
+		{
+			`{"name": "Gorilla"}[fn(x) { x }];`,
+			"unusable as hash key: FUNCTION",
+		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"3 / 0",
+			"division by zero",
+		},
+		{
+			"1 % 0",
+			"modulo by zero",
+		},
+		{
+			"3 % 0",
+			"modulo by zero",
+		},
+		{
+			"'abcd'['a']",
+			"index operator not supported: STRING",
+		},
+		{
+			"1.5 / 0",
+			"division by zero",
+		},
+		{
+			"true && foobar",
+			"identifier not found: foobar",
+		},
+		{
+			`"a" <= 1`,
+			"type mismatch: STRING <= INTEGER",
+		},
+		{
+			"-1.5 + true",
+			"type mismatch: FLOAT + BOOLEAN",
+		},
+		{
+			"sqrt(-1)",
+			"argument to `sqrt` must not be negative, got -1",
+		},
+		{
+			`int("abc")`,
+			`could not convert "abc" to INTEGER`,
+		},
+		{
+			"int(1e19)",
+			"could not convert 1e+19 to INTEGER",
+		},
+		{
+			"int(9.3e18)",
+			"could not convert 9.3e+18 to INTEGER",
+		},
+		{
+			"int(9.223372036854775808e18)",
+			"could not convert 9.223372036854776e+18 to INTEGER",
+		},
+		{
+			"int(-1e300)",
+			"could not convert -1e+300 to INTEGER",
+		},
+		{
+			"pow(2, 63)",
+			"integer overflow in `pow`: 2 to the power of 63",
+		},
+		{
+			"pow(-3, 100)",
+			"integer overflow in `pow`: -3 to the power of 100",
+		},
+		{
+			"try { throw 1 } catch (e) { 0 }; e",
+			"identifier not found: e",
+		},
+		{
+			"let f = fn() { try { throw 1 } catch (e) { let y = e }; y }; f()",
+			"identifier not found: y",
+		},
+		{
+			"for (i in [1, 2]) { try { throw i } catch (e) { if (e == 2) { y } let y = e } }",
+			"identifier not found: y",
+		},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func testCallErrors(t *testing.T, e Engine) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"let f = fn(a, b) { a }; f(1)", "wrong number of arguments: want=2, got=1"},
+		{"let x = 1; x()", "not a function: INTEGER"},
+		{"let f = fn() { f() }; f()", "stack overflow"},
+		{"let f = fn() { let y = x; let x = 1; y }; f()", "identifier not found: x"},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func testAssignmentErrors(t *testing.T, e Engine) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"x = 1", "cannot assign to undeclared identifier: x"},
+		{"x += 1", "identifier not found: x"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let arr = [1]; arr[1] = 2", "index out of range: 1"},
+		{"let arr = [1]; arr[-1] = 2", "index out of range: -1"},
+		{`let arr = [1]; arr["a"] = 2`, "array index must be INTEGER, got STRING"},
+		{`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING"},
+		{`let h = {}; h[fn() {}] = 1`, "unusable as hash key: FUNCTION"},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func testForErrors(t *testing.T, e Engine) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"for (x in [1, true]) { x + 1 }", "type mismatch: BOOLEAN + INTEGER"},
+		{"range(1, 2, 0)", "`range` step must not be zero"},
+		{"range(-9223372036854775807 - 1, 9223372036854775807)", "`range` has more values than an INTEGER can count"},
+		{`range("a")`, "argument to `range` must be INTEGER, got STRING"},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func testErrorPositions(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"foobar", "ERROR: 1:1: identifier not found: foobar"},
+		{"let x = 1;\nlet y = x + true;", "ERROR: 2:9: type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn() {\n  -true\n};\nf();", "ERROR: 2:3: unknown operator: -BOOLEAN"},
+		{"\n  len(1)", "ERROR: 2:3: argument to `len` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+		if errObj.Inspect() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q",
+				tt.expected, errObj.Inspect())
+		}
+	}
+}
+
+func testErrorStackTraces(t *testing.T, e Engine) {
+	input := `let inner = fn(a, b) {
+	a + b
+};
+let outer = fn(x) {
+	inner(x, true)
+};
+outer(5);`
+
+	evaluated := e.eval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []struct {
+		function string
+		pos      string
+		args     int
+	}{
+		{"inner", "5:2", 2},
+		{"outer", "7:1", 1},
+	}
+
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong number of stack frames. want=%d, got=%d",
+			len(expected), len(errObj.Stack))
+	}
+
+	for i, tt := range expected {
+		frame := errObj.Stack[i]
+		if frame.Function != tt.function {
+			t.Errorf("frame %d: wrong function. want=%q, got=%q",
+				i, tt.function, frame.Function)
+		}
+		if frame.Pos.String() != tt.pos {
+			t.Errorf("frame %d: wrong position. want=%q, got=%q",
+				i, tt.pos, frame.Pos.String())
+		}
+		if len(frame.Args) != tt.args {
+			t.Errorf("frame %d: wrong number of args. want=%d, got=%d",
+				i, tt.args, len(frame.Args))
+		}
+	}
+
+	trace := `ERROR: 2:2: type mismatch: INTEGER + BOOLEAN
+    at inner(5, true) (5:2)
+    at outer(5) (7:1)`
+	if errObj.StackTrace() != trace {
+		t.Errorf("wrong stack trace. want=%q, got=%q", trace, errObj.StackTrace())
+	}
+}
+
+func testCallErrorTraces(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b) { a };\nf(1)",
+			"ERROR: 2:1: wrong number of arguments: want=2, got=1\n    at f(1) (2:1)"},
+		{"let f = fn(a, b) { a };\nlet g = fn(x) {\n  f(x)\n};\ng(1)",
+			"ERROR: 3:3: wrong number of arguments: want=2, got=1\n    at f(1) (3:3)\n    at g(1) (5:1)"},
+		{"let add = fn(a, b) { a + b };\nmap([1], add)",
+			"ERROR: 2:1: wrong number of arguments: want=2, got=1\n    at map([1], fn(a, b) {\n(a + b)\n}) (2:1)"},
+		{"let x = 1;\nx(2)", "ERROR: 2:1: not a function: INTEGER\n    at x(2) (2:1)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.StackTrace() != tt.expected {
+			t.Errorf("wrong stack trace for %q. want=%q, got=%q",
+				tt.input, tt.expected, errObj.StackTrace())
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package enginetest
+
+import (
+	"gorilla/object"
+	"testing"
+)
+
+func testIntegerExpression(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"5 % 5 + 5 % 5 - 10", -10},
+		{"6 % 5 + 11 % 5 - 10", -8},
+		{"2 % 2 * 2 % 2 * 2", 0},
+		{"5 % 2 * 3 % 2 * 2", 2},
+		{"2 * 2 % 2 % 2 + 2", 2},
+		{"pow(2, 3)", 8},
+		{"pow(3, 2)", 9},
+		{"pow(7, 0)", 1},
+		{"pow(-3, 3)", -27},
+		{"pow(2, 62)", 4611686018427387904},
+		{"pow(-2, 63)", -9223372036854775808},
+		{"pow(1, 9223372036854775807)", 1},
+		{"pow(-1, 9223372036854775807)", -1},
+		{"pow(0, 9223372036854775807)", 0},
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{"int(9.2e18)", 9200000000000000000},
+		{"int(-9.223372036854775808e18)", -9223372036854775808},
+		{`int("42")`, 42},
+		{"5 # five", 5},
+		{"5\n# a comment after the last expression", 5},
+		{"2 * /* three */ 3", 6},
+		{"[1, # one\n 2][1]", 2},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func testFloatExpression(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"2.5E+3", 2500},
+		{"-1.5", -1.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 / 4.0", 2.5},
+		{"2 * 1.25", 2.5},
+		{"7.5 % 2", 1.5},
+		{"(1 + 2.0) * 3", 9},
+		{"sqrt(4)", 2},
+		{"sqrt(2.25)", 1.5},
+		{"pow(2, -1)", 0.5},
+		{"pow(4, 0.5)", 2},
+		{"pow(1.5, 2)", 2.25},
+		{"float(3)", 3},
+		{`float("2.5")`, 2.5},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func testBooleanExpression(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"0.1 + 0.2 == 0.3", false},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 <= 1", false},
+		{"2 >= 1.5", true},
+		{`"a" < "b"`, true},
+		{`"b" <= "b"`, true},
+		{`"abc" >= "abd"`, false},
+		{`"b" > "a"`, true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"!(1 > 2) && 1 <= 1", true},
+		{"1 && 0", true},
+		{`"" && 1`, true},
+		{"false && foobar", false},
+		{"true || foobar", true},
+		{"let calls = 0; let f = fn() { calls += 1; true }; false && f(); calls == 0", true},
+		{"let calls = 0; let f = fn() { calls += 1; true }; true || f(); calls == 0", true},
+		{"let calls = 0; let f = fn() { calls += 1; true }; true && f(); calls == 1", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func testBangOperator(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func testIfElseExpressions(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testReturnStatements(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{
+			`
+			if (10 > 1) {
+				if (10 > 1) {
+					return 10;
+				}
+				return 1;
+			}
+			`,
+			10,
+		},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func testLetStatements(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let größe = 5; let café = größe * 2; café;", 10},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, e.eval(tt.input), tt.expected)
+	}
+}
+
+func testFunctionApplication(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, e.eval(tt.input), tt.expected)
+	}
+}
+
+func testClosures(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let newAdder = fn(a) { fn(b) { a + b } }; newAdder(2)(3)", 5},
+		{"let f = fn(a) { let g = fn() { fn() { a * 2 } }; g()() }; f(21)", 42},
+		{`let make = fn() {
+			let n = 0;
+			let inc = fn() { n += 1 };
+			let get = fn() { n };
+			[inc, get]
+		};
+		let fs = make();
+		fs[0](); fs[0]();
+		fs[1]()`, 2},
+		// Blocks don't open a scope, so every closure sees the last i
+		{`let fs = [];
+		for (i in range(3)) { fs = push(fs, fn() { i }) };
+		fs[0]()`, 2},
+		{`let fib = fn(n) { if (n < 2) { return n } fib(n - 1) + fib(n - 2) }; fib(15)`, 610},
+		{`let f = fn() { let even = fn(n) { if (n == 0) { 1 } else { odd(n - 1) } };
+			let odd = fn(n) { if (n == 0) { 0 } else { even(n - 1) } };
+			even(10) };
+		f()`, 1},
+		{"let f = fn(a, b) { a }; f(1, 2, 3)", 1},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, e.eval(tt.input), tt.expected)
+	}
+}
+
+func testAssignExpressions(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 2", 3},
+		{"let x = 10; x += 5; x", 15},
+		{"let x = 10; x -= 5; x", 5},
+		{"let x = 10; x *= 5; x", 50},
+		{"let x = 10; x /= 5; x", 2},
+		{"let x = 10; x %= 4; x", 2},
+		{"let a = 1; let b = 2; a = b = 3; a + b", 6},
+		{`let s = "ab"; s += "c"; s`, "abc"},
+		{"let x = 1.5; x += 1; x", 2.5},
+		{"let x = 1; let f = fn() { x = x + 1 }; f(); f(); x", 3},
+		{"let x = 1; let f = fn() { let x = 5; x = 7 }; f(); x", 1},
+		{`let counter = fn() {
+			let n = 0;
+			fn() { n += 1; n }
+		};
+		let c = counter();
+		c(); c(); c()`, 3},
+		{`let i = 0; let total = 0;
+		let add = fn(v) { total += v };
+		while (i < 5) { i += 1; add(i) };
+		total`, 15},
+		{"let arr = [1, 2, 3]; arr[1] = 20; arr[1]", 20},
+		{"let arr = [1, 2, 3]; arr[2] += 10; arr[2]", 13},
+		{"let arr = [1, 2, 3]; let alias = arr; alias[0] = 9; arr[0]", 9},
+		{`let h = {"a": 1}; h["a"] = 2; h["a"]`, 2},
+		{`let h = {}; h["b"] = 3; h["b"]`, 3},
+		{`let h = {"n": 1}; h["n"] *= 4; h["n"]`, 4},
+		{`let grid = [[0, 0], [0, 0]]; grid[1][0] = 5; grid[1][0]`, 5},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package enginetest
+
+import (
+	"fmt"
+	"gorilla/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testImports(t *testing.T, e Engine) {
+	files := map[string]string{
+		"mathx.gor": `
+			let _base = 10;
+			let square = fn(x) { x * x };
+			let addBase = fn(x) { x + _base };
+		`,
+		"lib/strs.gor": `
+			import "../mathx.gor";
+			let twice = fn(s) { s + s };
+			let squareLen = fn(s) { mathx.square(len(s)) };
+		`,
+		"counter.gor": `
+			let count = 0;
+			let inc = fn() { count += 1; count };
+		`,
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "mathx.gor"; mathx.square(5)`, 25},
+		{`import "mathx"; mathx.square(3)`, 9},
+		{`import "mathx" as m; m.addBase(1)`, 11},
+		{`import "lib/strs.gor" as s; s.twice("ab")`, "abab"},
+		{`import "lib/strs" as s; s.squareLen("abc")`, 9},
+		{`import "counter" as a; import "counter" as b; a.inc(); b.inc(); a.count`, 2},
+		{`let f = fn() { import "mathx" as m; m.square(2) }; f()`, 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := e.evalInDir(t, files, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		}
+	}
+}
+
+func testImportSearchPath(t *testing.T, e Engine) {
+	lib := t.TempDir()
+	err := os.WriteFile(filepath.Join(lib, "util.gor"), []byte("let one = fn() { 1 };"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := object.NewSession()
+	session.SearchPath = []string{lib}
+
+	evaluated := e.evalInSession(session, t.TempDir(), `import "util"; util.one()`)
+	testIntegerObject(t, evaluated, 1)
+}
+
+func testImportSessions(t *testing.T, e Engine) {
+	counter := "let count = %d; let inc = fn() { count += 1; count };"
+	dir := writeFiles(t, map[string]string{"counter.gor": fmt.Sprintf(counter, 0)})
+	input := `import "counter"; counter.inc()`
+
+	// A session runs a module once, and keeps it even when the file changes
+	first := object.NewSession()
+	testIntegerObject(t, e.evalInSession(first, dir, input), 1)
+	testIntegerObject(t, e.evalInSession(first, dir, input), 2)
+
+	err := os.WriteFile(filepath.Join(dir, "counter.gor"), []byte(fmt.Sprintf(counter, 10)), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIntegerObject(t, e.evalInSession(first, dir, input), 3)
+	testIntegerObject(t, e.evalInSession(object.NewSession(), dir, input), 11)
+}
+
+func testImportErrors(t *testing.T, e Engine) {
+	files := map[string]string{
+		"a.gor":      `import "b"; let x = 1;`,
+		"b.gor":      `import "a"; let y = 2;`,
+		"broken.gor": `let = 1;`,
+		"fails.gor":  `let x = 1 + true;`,
+		"priv.gor":   `let _hidden = 1;`,
+	}
+
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`import "missing"`, "module not found: missing"},
+		{`import "priv"; priv._hidden`, "_hidden is not exported by module priv"},
+		{`import "priv"; priv.nothing`, "module priv has no member nothing"},
+		{`import "fails"`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let x = 5; x.y`, "member access not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := e.evalInDir(t, files, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+
+	cycle := e.evalInDir(t, files, `import "a"`)
+	errObj, ok := cycle.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned for import cycle. got=%T(%+v)", cycle, cycle)
+	}
+	modules := []string{}
+	for _, path := range strings.Split(strings.TrimPrefix(errObj.Message, "import cycle: "), " -> ") {
+		modules = append(modules, filepath.Base(path))
+	}
+	if strings.Join(modules, " -> ") != "a.gor -> b.gor -> a.gor" {
+		t.Errorf("wrong error message for import cycle. got=%q", errObj.Message)
+	}
+
+	broken := e.evalInDir(t, files, `import "broken"`)
+	errObj, ok = broken.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned for broken module. got=%T(%+v)", broken, broken)
+	}
+	if !strings.HasPrefix(errObj.Message, "parser errors in module broken:") {
+		t.Errorf("wrong error message for broken module. got=%q", errObj.Message)
+	}
+}
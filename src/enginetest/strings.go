@@ -0,0 +1,223 @@
+package enginetest
+
+import (
+	"gorilla/object"
+	"testing"
+)
+
+func testStringLiteral(t *testing.T, e Engine) {
+	input := `"Hello World!"`
+	evaluated := e.eval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func testStringConcatenation(t *testing.T, e Engine) {
+	input := `"Hello" + " " + "World!"`
+	evaluated := e.eval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func testTemplateLiterals(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"${1}"`, "1"},
+		{`let name = "Ann"; "Hello ${name}!"`, "Hello Ann!"},
+		{`let items = [1, 2]; "${len(items)} items: ${items}"`, "2 items: [1, 2]"},
+		{`"${1 + 2}${2.5} ${true} ${if (false) { 1 }}"`, "32.5 true null"},
+		{`let f = fn(x) { "<${x}>" }; f("a") + f(1)`, "<a><1>"},
+		{`"a ${ "b ${ {"k": "c"}["k"] } d" } e"`, "a b c d e"},
+		{`"\${x} costs \$5 or $5"`, "${x} costs $5 or $5"},
+		{`'${x}'`, "${x}"},
+		{"\"\"\"\nline 1\n  \"line\" 2 ${x} \\n\"\"\"", "line 1\n  \"line\" 2 ${x} \\n"},
+		{`""""""`, ""},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
+func testStringIndexExpressions(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"'abcd'[0]",
+			"a",
+		},
+		{
+			"\"abcd\"[0]",
+			"a",
+		},
+		{
+			"'abcd'[1]",
+			"b",
+		},
+		{
+			"'abcd'[2]",
+			"c",
+		},
+		{
+			"let i = 0; 'abcd'[i];",
+			"a",
+		},
+		{
+			"'abcd'[1 + 1];",
+			"c",
+		},
+		{
+			"let myStr = 'abcd'; myStr[2];",
+			"c",
+		},
+		{
+			"let myStr = 'abcd'; myStr[0] + myStr[1] + myStr[2];",
+			"abc",
+		},
+		{
+			"'abcd'[4]",
+			nil,
+		},
+		{
+			"'abcd'[-1]",
+			nil,
+		},
+		{
+			"'héllo'[1]",
+			"é",
+		},
+		{
+			"let nombre = 'Zoë 🦍'; nombre[2] + nombre[4]",
+			"ë🦍",
+		},
+		{
+			"'日本'[2]",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+
+		expectedStr, ok := tt.expected.(string)
+		if ok {
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			}
+
+			if str.Value != expectedStr {
+				t.Errorf("String has wrong value. got=%q", str.Value)
+			}
+		} else {
+			testNullObject(t, evaluated)
+		}
+
+	}
+}
+
+func testBuiltinFunctions(t *testing.T, e Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("🦍\u{1F34C}")`, 2},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+	}
+	for _, tt := range tests {
+		evaluated := e.eval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)",
+					evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func testStringBuiltins(t *testing.T, e Engine) {
+	testInspect(t, e, []inspectTest{
+		{`split("a,b,,c", ",")`, "[a, b, , c]"},
+		{`split("  one two\tthree ")`, "[one, two, three]"},
+		{`split("añ", "")`, "[a, ñ]"},
+		{`split(1)`, "argument 1 to `split` must be STRING, got INTEGER"},
+		{`split()`, "wrong number of arguments. got=0, want=1 or 2"},
+		{`join(["a", 1, true], ", ")`, "a, 1, true"},
+		{`join(["x", "y"])`, "xy"},
+		{`join("xy", "")`, "argument 1 to `join` must be ARRAY, got STRING"},
+		{`"[" + trim(" \t hi \n") + "]"`, "[hi]"},
+		{`trim("--hi-", "-")`, "hi"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("ÀB")`, "àb"},
+		{`upper(1)`, "argument to `upper` must be STRING, got INTEGER"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("a", "b")`, "wrong number of arguments. got=2, want=3"},
+		{`contains("gorilla", "ril")`, "true"},
+		{`contains("gorilla", "x")`, "false"},
+		{`startsWith("gorilla", "go")`, "true"},
+		{`endsWith("gorilla", "go")`, "false"},
+		{`indexOf("日本語", "語")`, "2"},
+		{`indexOf("abc", "z")`, "-1"},
+		{`substr("héllo", 1, 3)`, "éll"},
+		{`substr("héllo", 3)`, "lo"},
+		{`substr("abc", 1, 10)`, "bc"},
+		{`substr("abc", 1, 9223372036854775807)`, "bc"},
+		{`substr("abc", 9223372036854775807, 9223372036854775807)`, ""},
+		{`substr("abc", 5)`, ""},
+		{`substr("abc", -1)`, "`substr` start must not be negative, got -1"},
+		{`substr("abc", 0, -1)`, "`substr` length must not be negative, got -1"},
+		{`substr("abc", 0, "1")`, "argument 3 to `substr` must be INTEGER, got STRING"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, "`repeat` count must not be negative, got -1"},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`repeat("ab", 9223372036854775807)`, "`repeat` result would be longer than 1073741824 bytes"},
+		{`repeat("abc", 4611686018427387904)`, "`repeat` result would be longer than 1073741824 bytes"},
+		{`repeat("a", 1000000000000000000)`, "`repeat` result would be longer than 1073741824 bytes"},
+		{`repeat("ab", 536870913)`, "`repeat` result would be longer than 1073741824 bytes"},
+		{`len(repeat("a", 1000))`, "1000"},
+		{`chars("a🦍")`, "[a, 🦍]"},
+		{`format("%d items at %.2f", 3, 1.5)`, "3 items at 1.50"},
+		{`format("%s|%5s|%-3d|%t|%q|%v|%x|%%", [1], "r", 7, true, "q", 2.5, 255)`, "[1]|    r|7  |true|\"q\"|2.5|ff|%"},
+		{`format("%f", 1)`, "1.000000"},
+		{`format("%d", "x")`, "%d in format needs INTEGER, got STRING"},
+		{`format("%d %d", 1)`, `not enough arguments for format "%d %d"`},
+		{`format("a", 1)`, `too many arguments for format "a": got 1, want 0`},
+		{`format("%y", 1)`, "unknown verb %y in format"},
+		{`format("%")`, `format "%" ends in the middle of a verb`},
+		{`format(1)`, "argument 1 to `format` must be STRING, got INTEGER"},
+	})
+}
@@ -0,0 +1,300 @@
+package evaluator
+
+import (
+	"gorilla/object"
+	"sort"
+)
+
+// The array builtins return new arrays and leave the ones they are given
+// as they are. The callbacks of map, filter, reduce, each and sort may be
+// Gorilla functions or builtins.
+var arrayBuiltins = map[string]*object.Builtin{
+	"map": {
+		CallbackFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkCallbackArgs("map", args, 2); err != nil {
+				return err
+			}
+
+			arr, fn := args[0].(*object.Array), args[1]
+			result := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				value := call(fn, el)
+				if isError(value) {
+					return value
+				}
+				result[i] = value
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"filter": {
+		CallbackFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkCallbackArgs("filter", args, 2); err != nil {
+				return err
+			}
+
+			arr, fn := args[0].(*object.Array), args[1]
+			result := []object.Object{}
+			for _, el := range arr.Elements {
+				keep := call(fn, el)
+				if isError(keep) {
+					return keep
+				}
+				if isTruthy(keep) {
+					result = append(result, el)
+				}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"reduce": {
+		CallbackFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkCallbackArgs("reduce", args, 3); err != nil {
+				return err
+			}
+
+			arr, fn := args[0].(*object.Array), args[1]
+			elements := arr.Elements
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			} else if len(elements) > 0 {
+				acc, elements = elements[0], elements[1:]
+			} else {
+				return newError("`reduce` of an empty array needs an initial value")
+			}
+
+			for _, el := range elements {
+				acc = call(fn, acc, el)
+				if isError(acc) {
+					return acc
+				}
+			}
+			return acc
+		},
+	},
+	"each": {
+		CallbackFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkCallbackArgs("each", args, 2); err != nil {
+				return err
+			}
+
+			arr, fn := args[0].(*object.Array), args[1]
+			for _, el := range arr.Elements {
+				if result := call(fn, el); isError(result) {
+					return result
+				}
+			}
+			return NULL
+		},
+	},
+	"sort": {
+		CallbackFn: func(call object.Caller, args ...object.Object) object.Object {
+			if err := checkArgs("sort", args, 1, object.ARRAY_OBJ, ""); err != nil {
+				return err
+			}
+			if len(args) == 2 && !isFunction(args[1]) {
+				return newError("argument 2 to `sort` must be a function, got %s", args[1].Type())
+			}
+
+			// less reports whether a goes before b: the comparator says so,
+			// or else a < b
+			less := func(a, b object.Object) object.Object {
+				if len(args) == 2 {
+					return call(args[1], a, b)
+				}
+				return evalInfixExpression("<", a, b, "")
+			}
+
+			elements := append([]object.Object(nil), args[0].(*object.Array).Elements...)
+			var err object.Object
+			sort.SliceStable(elements, func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+				result := less(elements[i], elements[j])
+				if isError(result) {
+					err = result
+					return false
+				}
+				if result.Type() != object.BOOLEAN_OBJ {
+					err = newError("`sort` comparator must return BOOLEAN, got %s", result.Type())
+					return false
+				}
+				return result == TRUE
+			})
+			if err != nil {
+				return err
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"reverse": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("reverse", args, 1, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			result := make([]object.Object, len(elements))
+			for i, el := range elements {
+				result[len(elements)-1-i] = el
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"slice": {
+		Fn: func(args ...object.Object) object.Object {
+			err := checkArgs("slice", args, 2, object.ARRAY_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ)
+			if err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			start := args[1].(*object.Integer).Value
+			if start < 0 {
+				return newError("`slice` start must not be negative, got %d", start)
+			}
+			start = min(start, int64(len(elements)))
+
+			end := int64(len(elements))
+			if len(args) == 3 {
+				end = args[2].(*object.Integer).Value
+				if end < 0 {
+					return newError("`slice` end must not be negative, got %d", end)
+				}
+				end = min(max(start, end), int64(len(elements)))
+			}
+
+			result := make([]object.Object, end-start)
+			copy(result, elements[start:end])
+			return &object.Array{Elements: result}
+		},
+	},
+	"concat": {
+		Fn: func(args ...object.Object) object.Object {
+			arrays, err := arrayArgs("concat", args)
+			if err != nil {
+				return err
+			}
+
+			result := []object.Object{}
+			for _, arr := range arrays {
+				result = append(result, arr.Elements...)
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"zip": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want=1 or more",
+					len(args))
+			}
+			arrays, err := arrayArgs("zip", args)
+			if err != nil {
+				return err
+			}
+
+			// As long as the shortest array
+			n := len(arrays[0].Elements)
+			for _, arr := range arrays[1:] {
+				n = min(n, len(arr.Elements))
+			}
+
+			result := make([]object.Object, n)
+			for i := range result {
+				tuple := make([]object.Object, len(arrays))
+				for j, arr := range arrays {
+					tuple[j] = arr.Elements[i]
+				}
+				result[i] = &object.Array{Elements: tuple}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"flatten": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("flatten", args, 1, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+
+			// Only one level of nesting is removed
+			result := []object.Object{}
+			for _, el := range args[0].(*object.Array).Elements {
+				if arr, ok := el.(*object.Array); ok {
+					result = append(result, arr.Elements...)
+				} else {
+					result = append(result, el)
+				}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"unique": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("unique", args, 1, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+
+			unique := &object.Array{Elements: []object.Object{}}
+			for _, el := range args[0].(*object.Array).Elements {
+				if indexOf(unique, el) < 0 {
+					unique.Elements = append(unique.Elements, el)
+				}
+			}
+			return unique
+		},
+	},
+}
+
+func init() {
+	for name, builtin := range arrayBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// checkCallbackArgs checks a call to the builtin name that takes an array, a
+// function and, when it takes more than two arguments, optional others.
+func checkCallbackArgs(name string, args []object.Object, n int) *object.Error {
+	types := make([]object.ObjectType, n)
+	types[0] = object.ARRAY_OBJ
+	if err := checkArgs(name, args, 2, types...); err != nil {
+		return err
+	}
+	if !isFunction(args[1]) {
+		return newError("argument 2 to `%s` must be a function, got %s", name, args[1].Type())
+	}
+	return nil
+}
+
+// arrayArgs returns args, checking that they are all arrays.
+func arrayArgs(name string, args []object.Object) ([]*object.Array, *object.Error) {
+	arrays := make([]*object.Array, len(args))
+	for i, arg := range args {
+		arr, ok := arg.(*object.Array)
+		if !ok {
+			return nil, newError("argument %d to `%s` must be ARRAY, got %s",
+				i+1, name, arg.Type())
+		}
+		arrays[i] = arr
+	}
+	return arrays, nil
+}
+
+// isFunction reports whether obj can be called: a Gorilla function, in
+// either engine, or a builtin.
+func isFunction(obj object.Object) bool {
+	return obj.Type() == object.FUNCTION_OBJ || obj.Type() == object.BUILTIN_OBJ
+}
+
+// indexOf returns the index of the first element of arr equal to value, or
+// -1 if there is none.
+func indexOf(arr *object.Array, value object.Object) int {
+	for i, el := range arr.Elements {
+		if object.Equal(el, value) {
+			return i
+		}
+	}
+	return -1
+}
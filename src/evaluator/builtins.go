@@ -5,6 +5,9 @@ import (
 	"gorilla/object"
 	"math"
 	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
@@ -17,11 +20,13 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.Hash:
-				return &object.Integer{Value: int64(len(arg.Pairs))}
+				return &object.Integer{Value: int64(arg.Len())}
+			case *object.Range:
+				return &object.Integer{Value: arg.Len()}
 			default:
 				return newError("argument to `len` not supported, got %s",
 					args[0].Type())
@@ -165,6 +170,40 @@ var builtins = map[string]*object.Builtin{
 			return &object.String{Value: args[0].Inspect()}
 		},
 	},
+	"same": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+
+			return nativeBoolToBooleanObject(object.Same(args[0], args[1]))
+		},
+	},
+	"freeze": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			frozen, ok := object.Freeze(args[0])
+			if !ok {
+				return newError("cannot freeze a value that contains itself")
+			}
+			return frozen
+		},
+	},
+	"isFrozen": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			return nativeBoolToBooleanObject(isFrozen(args[0]))
+		},
+	},
 	"exit": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -173,7 +212,7 @@ var builtins = map[string]*object.Builtin{
 			}
 
 			if args[0].Type() != object.INTEGER_OBJ {
-				return newError("argument to must be INTEGER, got %s",
+				return newError("argument to `exit` must be INTEGER, got %s",
 					args[0].Type())
 			}
 
@@ -184,22 +223,34 @@ var builtins = map[string]*object.Builtin{
 	"pow": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=1",
+				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
 			}
 
-			if args[0].Type() != object.INTEGER_OBJ {
-				return newError("argument to must be INTEGER, got %s",
+			if !isNumber(args[0]) {
+				return newError("argument to `pow` must be INTEGER or FLOAT, got %s",
 					args[0].Type())
-			} else if args[1].Type() != object.INTEGER_OBJ {
-				return newError("argument to must be INTEGER, got %s",
+			} else if !isNumber(args[1]) {
+				return newError("argument to `pow` must be INTEGER or FLOAT, got %s",
 					args[1].Type())
 			}
 
-			x := float64(args[0].(*object.Integer).Value)
-			y := float64(args[1].(*object.Integer).Value)
+			// An integer raised to a non-negative integer stays exact
+			base, ok1 := args[0].(*object.Integer)
+			exp, ok2 := args[1].(*object.Integer)
+			if ok1 && ok2 && exp.Value >= 0 {
+				result, ok := powInt(base.Value, exp.Value)
+				if !ok {
+					return newError("integer overflow in `pow`: %d to the power of %d",
+						base.Value, exp.Value)
+				}
+				return &object.Integer{Value: result}
+			}
+
+			x := toFloat(args[0])
+			y := toFloat(args[1])
 
-			return &object.Integer{Value: int64(math.Pow(x, y))}
+			return &object.Float{Value: math.Pow(x, y)}
 		},
 	},
 	"sqrt": {
@@ -209,14 +260,142 @@ var builtins = map[string]*object.Builtin{
 					len(args))
 			}
 
-			if args[0].Type() != object.INTEGER_OBJ {
-				return newError("argument to must be INTEGER, got %s",
+			if !isNumber(args[0]) {
+				return newError("argument to `sqrt` must be INTEGER or FLOAT, got %s",
 					args[0].Type())
 			}
 
-			x := float64(args[0].(*object.Integer).Value)
+			x := toFloat(args[0])
+			if x < 0 {
+				return newError("argument to `sqrt` must not be negative, got %s",
+					args[0].Inspect())
+			}
 
-			return &object.Integer{Value: int64(math.Sqrt(x))}
+			return &object.Float{Value: math.Sqrt(x)}
 		},
 	},
+	"range": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1, 2 or 3",
+					len(args))
+			}
+
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("argument to `range` must be INTEGER, got %s",
+						arg.Type())
+				}
+				bounds[i] = integer.Value
+			}
+
+			r := &object.Range{Start: 0, Step: 1}
+			switch len(bounds) {
+			case 1:
+				r.Stop = bounds[0]
+			case 2:
+				r.Start, r.Stop = bounds[0], bounds[1]
+			case 3:
+				r.Start, r.Stop, r.Step = bounds[0], bounds[1], bounds[2]
+			}
+
+			if r.Step == 0 {
+				return newError("`range` step must not be zero")
+			}
+			if !r.Fits() {
+				return newError("`range` has more values than an INTEGER can count")
+			}
+			return r
+		},
+	},
+	"float": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Float:
+				return arg
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError("could not convert %q to FLOAT", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return newError("argument to `float` not supported, got %s",
+					args[0].Type())
+			}
+		},
+	},
+	"int": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				// -MinInt64 is 2**63, the first float too big for an INTEGER.
+				// NaN fails both comparisons.
+				if !(arg.Value >= math.MinInt64 && arg.Value < -math.MinInt64) {
+					return newError("could not convert %s to INTEGER", arg.Inspect())
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.String:
+				value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+				if err != nil {
+					return newError("could not convert %q to INTEGER", arg.Value)
+				}
+				return &object.Integer{Value: value}
+			default:
+				return newError("argument to `int` not supported, got %s",
+					args[0].Type())
+			}
+		},
+	},
+}
+
+// powInt raises base to the non-negative power exp by repeated squaring. It
+// reports false if the result doesn't fit in an int64.
+func powInt(base, exp int64) (int64, bool) {
+	result := int64(1)
+	for exp > 0 {
+		var ok bool
+		if exp&1 == 1 {
+			if result, ok = mulInt(result, base); !ok {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		// The square is only needed, and only has to fit, if a higher bit
+		// of exp is left
+		if exp > 0 {
+			if base, ok = mulInt(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// mulInt multiplies a and b, and reports false if the product overflows.
+func mulInt(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
 }
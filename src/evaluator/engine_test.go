@@ -0,0 +1,15 @@
+package evaluator_test
+
+import (
+	"gorilla/ast"
+	"gorilla/enginetest"
+	"gorilla/evaluator"
+	"gorilla/object"
+	"testing"
+)
+
+func TestEngine(t *testing.T) {
+	enginetest.Run(t, func(program *ast.Program, session *object.Session) object.Object {
+		return evaluator.Eval(program, object.NewSessionEnvironment(session))
+	})
+}
@@ -5,20 +5,32 @@ import (
 	"gorilla/ast"
 	"gorilla/debug"
 	"gorilla/object"
+	"math"
+	"strings"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 // func Eval(node ast.Node, indent string) object.Object {
-func Eval(node ast.Node, env *object.Environment, opt_indent ...string) object.Object {
+func Eval(node ast.Node, env *object.Environment, opt_indent ...string) (result object.Object) {
 	var indent string = ""
 	if len(opt_indent) == 1 {
 		indent = opt_indent[0]
 	}
+
+	// Errors are created without a location. The innermost node that
+	// produces one stamps its own position on it on the way out.
+	defer func() {
+		if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+			err.Pos = node.Pos()
+		}
+	}()
 	// defer untrace(trace("Eval"))
 
 	switch node := node.(type) {
@@ -45,6 +57,13 @@ func Eval(node ast.Node, env *object.Environment, opt_indent ...string) object.O
 
 		return v
 
+	case *ast.FloatLiteral:
+		v := &object.Float{Value: node.Value}
+
+		debug.PrintEvaluationEnd(indent, "ast.FloatLiteral", v)
+
+		return v
+
 	case *ast.Boolean:
 		debug.PrintEvaluationStart(indent, "ast.Boolean")
 
@@ -57,6 +76,14 @@ func Eval(node ast.Node, env *object.Environment, opt_indent ...string) object.O
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.TemplateLiteral:
+		parts := evalExpressions(node.Parts, env)
+		if len(parts) == 1 && isError(parts[0]) {
+			return parts[0]
+		}
+
+		return interpolate(parts)
+
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -95,6 +122,10 @@ func Eval(node ast.Node, env *object.Environment, opt_indent ...string) object.O
 		return v
 
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env, indent)
+		}
+
 		debug.PrintEvaluationStart(indent, "ast.InfixExpression")
 
 		left := Eval(node.Left, env, indent+"    ")
@@ -132,6 +163,42 @@ func Eval(node ast.Node, env *object.Environment, opt_indent ...string) object.O
 	case *ast.WhileExpression:
 		return evalWhileExpression(node, env)
 
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.ImportStatement:
+		module := evalImport(node, env)
+		if isError(module) {
+			return module
+		}
+		env.Set(ImportName(node), module)
+
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Member.Value)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	case *ast.ForExpression:
+		return evalForExpression(node, env)
+
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env, indent)
+		if isError(val) {
+			return val
+		}
+		return ThrownError(val)
+
 	case *ast.LetStatement:
 		val := Eval(node.Value, env, indent)
 		if isError(val) {
@@ -145,7 +212,7 @@ func Eval(node ast.Node, env *object.Environment, opt_indent ...string) object.O
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: node.Name, Parameters: params, Env: env, Body: body}
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -158,7 +225,16 @@ func Eval(node ast.Node, env *object.Environment, opt_indent ...string) object.O
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		v := ApplyFunction(function, args)
+		if err, ok := v.(*object.Error); ok {
+			err.Stack = append(err.Stack, object.StackFrame{
+				Function: functionName(function, node.Function),
+				Pos:      node.Pos(),
+				Args:     args,
+			})
+		}
+
+		return v
 
 	case *ast.ReturnStatement:
 		debug.PrintEvaluationStart(indent, "ast.ReturnStatement")
@@ -210,20 +286,31 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment, inde
 		result = Eval(statement, env, indent)
 
 		// Here we explicitly don’t unwrap the return value and only check the Type() of each evaluation result. If it’s object.RETURN_VALUE_OBJ we simply return the *object.ReturnValue, without unwrapping its .Value, so it stops execution in a possible outer block statement and bubbles up to evalProgram, where it finally get’s unwrapped.
-		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+		// Break and continue signals bubble up the same way to the nearest loop.
+		if isControlSignal(result) {
 
-				debug.PrintEvaluationEnd(indent, "\n"+indent+"Returning out of block", result)
+			debug.PrintEvaluationEnd(indent, "\n"+indent+"Returning out of block", result)
 
-				return result
-			}
+			return result
 		}
 	}
 
 	return result
 }
 
+// isControlSignal reports whether obj has to stop the evaluation of the
+// enclosing block: a return value, an error, break or continue.
+func isControlSignal(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	}
+	return false
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	//defer untrace(trace("nativeBoolToBooleanObject"))
 	if input {
@@ -263,11 +350,41 @@ func evalBangOperatorExpression(right object.Object, indent string) object.Objec
 func evalMinusPrefixOperatorExpression(right object.Object, indent string) object.Object {
 	//defer untrace(trace("evalMinusPrefixOperatorExpression"))
 
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+}
+
+// evalLogicalExpression evaluates && and ||. The right operand is only
+// evaluated when the left one doesn't already decide the result.
+func evalLogicalExpression(
+	node *ast.InfixExpression,
+	env *object.Environment,
+	indent string,
+) object.Object {
+	left := Eval(node.Left, env, indent+"    ")
+	if isError(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(node.Right, env, indent+"    ")
+	if isError(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
 }
 
 func evalInfixExpression(
@@ -281,14 +398,18 @@ func evalInfixExpression(
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 
+	case isNumber(left) && isNumber(right):
+		// At least one side is a float, so the other one is promoted
+		return evalFloatInfixExpression(operator, left, right)
+
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 
 	case operator == "==":
-		// We’re using pointer comparison here to check for equality between booleans. That works because we're always using pointers to our objects and in the case of booleans we only ever use two: TRUE and FALSE
-		return nativeBoolToBooleanObject(left == right)
+		// Arrays and hashes are compared by their contents
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
@@ -329,6 +450,52 @@ func evalIntegerInfixExpression(
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+func evalFloatInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("modulo by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -339,6 +506,22 @@ func evalIntegerInfixExpression(
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	rt := obj.Type()
+	return rt == object.INTEGER_OBJ || rt == object.FLOAT_OBJ
+}
+
+// toFloat converts an INTEGER or FLOAT object to a float64.
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment, indent string) object.Object {
 	//defer untrace(trace("evalIfExpression"))
 
@@ -382,6 +565,26 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// isFrozen reports whether obj is a frozen array or hash.
+func isFrozen(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return obj.Frozen
+	case *object.Hash:
+		return obj.Frozen
+	}
+	return false
+}
+
+// checkNotFrozen returns an error if obj is frozen, for code about to
+// change it.
+func checkNotFrozen(obj object.Object) *object.Error {
+	if isFrozen(obj) {
+		return newError("cannot change a frozen %s", obj.Type())
+	}
+	return nil
+}
+
 func evalIdentifier(
 	node *ast.Identifier,
 	env *object.Environment,
@@ -389,12 +592,25 @@ func evalIdentifier(
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
+	if builtin, ok := env.Session().Builtins[node.Value]; ok {
+		return builtin
+	}
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
 	return newError("identifier not found: " + node.Value)
 }
 
+// interpolate joins the parts of a template literal into a string, writing
+// each value the way string() does.
+func interpolate(parts []object.Object) object.Object {
+	var out strings.Builder
+	for _, part := range parts {
+		out.WriteString(part.Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
 func evalExpressions(
 	exps []ast.Expression,
 	env *object.Environment,
@@ -413,21 +629,50 @@ func evalExpressions(
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// ApplyFunction calls fn, a Gorilla function or builtin, with args and
+// returns its result. A failed call returns an *object.Error.
+func ApplyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) < len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d",
+				len(fn.Parameters), len(args))
+		}
+		session := fn.Env.Session()
+		if session.MaxDepth > 0 && session.Depth >= session.MaxDepth {
+			return newError("stack overflow")
+		}
+		session.Depth++
+		defer func() { session.Depth-- }()
+
 		extendedEnv := extendFunctionEnv(fn, args)
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return fn.Call(callFunction, args...)
 
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// callFunction is the object.Caller of the evaluator.
+func callFunction(fn object.Object, args ...object.Object) object.Object {
+	return ApplyFunction(fn, args)
+}
+
+// functionName returns the name a call shows up as in a stack trace.
+func functionName(fn object.Object, callee ast.Expression) string {
+	if fn, ok := fn.(*object.Function); ok && fn.Name != "" {
+		return fn.Name
+	}
+	if ident, ok := callee.(*ast.Identifier); ok {
+		return ident.Value
+	}
+	return "<anonymous>"
+}
+
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
@@ -450,25 +695,25 @@ func evalStringInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
-	if operator == "+" {
-		leftVal := left.(*object.String).Value
-		rightVal := right.(*object.String).Value
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
 
+	switch operator {
+	case "+":
 		return &object.String{Value: leftVal + rightVal}
-
-	} else if operator == "==" {
-		leftVal := left.(*object.String).Value
-		rightVal := right.(*object.String).Value
-
+	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
-
-	} else if operator == "!=" {
-		leftVal := left.(*object.String).Value
-		rightVal := right.(*object.String).Value
-
+	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
-
-	} else {
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
@@ -504,15 +749,16 @@ func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
+		valueNode := node.Pairs[keyNode]
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
 			return newError("unusable as hash key: %s", key.Type())
 		}
@@ -522,52 +768,51 @@ func evalHashLiteral(
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
 
 func evalStringIndexExpression(str, index object.Object) object.Object {
 	stringObject := str.(*object.String)
 
+	// Strings are indexed by char
+	chars := []rune(stringObject.Value)
 	idx := index.(*object.Integer).Value
-	max := int64(len(stringObject.Value) - 1)
+	max := int64(len(chars) - 1)
 
 	if idx < 0 || idx > max {
 		return NULL
 	}
 
-	return &object.String{Value: string(stringObject.Value[idx])}
+	return &object.String{Value: string(chars[idx])}
 
 }
 
 func evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
-	condition := Eval(we.Condition, env)
-	if isError(condition) {
-		return condition
-	}
-
 	var result object.Object = NULL
 	for {
 		condition := Eval(we.Condition, env)
+		if isError(condition) {
+			return condition
+		}
 
 		if !isTruthy(condition) {
 			if result == nil {
@@ -577,10 +822,259 @@ func evalWhileExpression(we *ast.WhileExpression, env *object.Environment) objec
 		}
 
 		result = Eval(we.Body, env)
-		switch result := result.(type) {
-		case *object.ReturnValue:
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error:
 			return result
+		case *object.Break:
+			return NULL
+		case *object.Continue:
+			result = NULL
+		}
+	}
+}
+
+func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := Eval(fe.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	_, isHash := iterable.(*object.Hash)
+
+	var result object.Object = NULL
+	err := forEach(iterable, func(key, value object.Object) bool {
+		if fe.Key != nil {
+			env.Set(fe.Key.Value, key)
+			env.Set(fe.Value.Value, value)
+		} else if isHash {
+			env.Set(fe.Value.Value, key)
+		} else {
+			env.Set(fe.Value.Value, value)
+		}
+
+		result = Eval(fe.Body, env)
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error:
+			return false
+		case *object.Break:
+			result = NULL
+			return false
+		case *object.Continue:
+			result = NULL
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// forEach calls fn with every index and element of an array, key and value
+// of a hash (in insertion order), index and character of a string, or index
+// and value of a range, until fn returns false.
+func forEach(iterable object.Object, fn func(key, value object.Object) bool) *object.Error {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		// The length is checked on every step so that the loop sees
+		// elements changed by the body
+		for i := 0; i < len(iterable.Elements); i++ {
+			if !fn(&object.Integer{Value: int64(i)}, iterable.Elements[i]) {
+				break
+			}
+		}
+
+	case *object.Hash:
+		// Keys deleted by the body are skipped, and changed values seen
+		for _, pair := range iterable.Entries() {
+			value, ok := iterable.Get(pair.Key)
+			if !ok {
+				continue
+			}
+			if !fn(pair.Key, value) {
+				break
+			}
+		}
+
+	case *object.String:
+		for i, ch := range []rune(iterable.Value) {
+			char := &object.String{Value: string(ch)}
+			if !fn(&object.Integer{Value: int64(i)}, char) {
+				break
+			}
+		}
+
+	case *object.Range:
+		length := iterable.Len()
+		for i := int64(0); i < length; i++ {
+			value := &object.Integer{Value: iterable.Start + i*iterable.Step}
+			if !fn(&object.Integer{Value: i}, value) {
+				break
+			}
+		}
+
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	return nil
+}
+
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Block, env)
+
+	if err, ok := result.(*object.Error); ok && te.Catch != nil {
+		// The catch block gets a scope of its own, like a function body,
+		// so that the parameter doesn't overwrite or leak a variable
+		catchEnv := object.NewEnclosedEnvironment(env)
+		if te.CatchParam != nil {
+			catchEnv.Set(te.CatchParam.Value, caughtValue(err))
+		}
+		result = Eval(te.Catch, catchEnv)
+	}
+
+	if te.Finally != nil {
+		// A return, break, continue or error coming out of finally replaces
+		// whatever the try or catch block produced.
+		finally := Eval(te.Finally, env)
+		if isControlSignal(finally) {
+			return finally
+		}
+	}
+
+	return result
+}
+
+// thrownMessage is the message an uncaught thrown value is reported with.
+func thrownMessage(val object.Object) string {
+	switch val := val.(type) {
+	case *object.String:
+		return val.Value
+	case *object.Hash:
+		key := &object.String{Value: "message"}
+		if value, ok := val.Get(key); ok {
+			return value.Inspect()
+		}
+	}
+	return val.Inspect()
+}
+
+// caughtValue is what a catch clause binds for err: the thrown value itself,
+// or a hash describing an error raised by the interpreter.
+func caughtValue(err *object.Error) object.Object {
+	if err.Value != nil {
+		return err.Value
+	}
+
+	hash := object.NewHash()
+	fields := []struct {
+		name  string
+		value object.Object
+	}{
+		{"message", &object.String{Value: err.Message}},
+		{"file", &object.String{Value: err.Pos.Filename}},
+		{"line", &object.Integer{Value: int64(err.Pos.Line)}},
+		{"column", &object.Integer{Value: int64(err.Pos.Column)}},
+	}
+	for _, f := range fields {
+		key := &object.String{Value: f.name}
+		hash.Set(key, f.value)
+	}
+
+	return hash
+}
+
+func evalAssignExpression(ae *ast.AssignExpression, env *object.Environment) object.Object {
+	// "+=" applies "+" to the current value, and so on
+	operator := strings.TrimSuffix(ae.Operator, "=")
+
+	switch target := ae.Target.(type) {
+	case *ast.Identifier:
+		val := Eval(ae.Value, env)
+		if isError(val) {
+			return val
+		}
+
+		if operator != "" {
+			current, ok := env.Get(target.Value)
+			if !ok {
+				return newError("identifier not found: " + target.Value)
+			}
+			val = evalInfixExpression(operator, current, val, "")
+			if isError(val) {
+				return val
+			}
+		}
+
+		if !env.Assign(target.Value, val) {
+			return newError("cannot assign to undeclared identifier: %s", target.Value)
+		}
+		return val
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
 		}
 
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		val := Eval(ae.Value, env)
+		if isError(val) {
+			return val
+		}
+
+		if operator != "" {
+			current := evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+			val = evalInfixExpression(operator, current, val, "")
+			if isError(val) {
+				return val
+			}
+		}
+
+		return evalIndexAssignment(left, index, val)
+
+	default:
+		return newError("cannot assign to %s", ae.Target.String())
+	}
+}
+
+func evalIndexAssignment(left, index, val object.Object) object.Object {
+	if err := checkNotFrozen(left); err != nil {
+		return err
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		if idx.Value < 0 || idx.Value >= int64(len(left.Elements)) {
+			return newError("index out of range: %d", idx.Value)
+		}
+		left.Elements[idx.Value] = val
+		return val
+
+	case *object.Hash:
+		key, ok := object.AsHashable(index)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.Set(key, val)
+		return val
+
+	default:
+		return newError("index assignment not supported: %s", left.Type())
 	}
 }
@@ -0,0 +1,213 @@
+package evaluator
+
+import "gorilla/object"
+
+// The hash builtins list pairs in the order their keys were added. delete
+// and merge return a new hash like push does, deleteInPlace and mergeInPlace
+// change the hash they are given and return it, unless it is frozen.
+var hashBuiltins = map[string]*object.Builtin{
+	"keys": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("keys", args, 1, object.HASH_OBJ); err != nil {
+				return err
+			}
+
+			return hashArray(args[0].(*object.Hash), func(pair object.HashPair) object.Object {
+				return pair.Key
+			})
+		},
+	},
+	"values": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("values", args, 1, object.HASH_OBJ); err != nil {
+				return err
+			}
+
+			return hashArray(args[0].(*object.Hash), func(pair object.HashPair) object.Object {
+				return pair.Value
+			})
+		},
+	},
+	"entries": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("entries", args, 1, object.HASH_OBJ); err != nil {
+				return err
+			}
+
+			return hashArray(args[0].(*object.Hash), func(pair object.HashPair) object.Object {
+				return &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			})
+		},
+	},
+	"fromEntries": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("fromEntries", args, 1, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+
+			hash := object.NewHash()
+			for _, entry := range args[0].(*object.Array).Elements {
+				pair, ok := entry.(*object.Array)
+				if !ok || len(pair.Elements) != 2 {
+					return newError("entry to `fromEntries` must be a [key, value] ARRAY, got %s",
+						entry.Inspect())
+				}
+				if err := setPair(hash, pair.Elements[0], pair.Elements[1]); err != nil {
+					return err
+				}
+			}
+			return hash
+		},
+	},
+	"has": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("has", args, 2, object.HASH_OBJ, ""); err != nil {
+				return err
+			}
+
+			key, err := hashKey(args[1])
+			if err != nil {
+				return err
+			}
+			_, ok := args[0].(*object.Hash).Get(key)
+			return nativeBoolToBooleanObject(ok)
+		},
+	},
+	"get": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("get", args, 2, object.HASH_OBJ, "", ""); err != nil {
+				return err
+			}
+
+			key, err := hashKey(args[1])
+			if err != nil {
+				return err
+			}
+			if value, ok := args[0].(*object.Hash).Get(key); ok {
+				return value
+			}
+			if len(args) == 3 {
+				return args[2]
+			}
+			return NULL
+		},
+	},
+	"delete": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("delete", args, 2, object.HASH_OBJ, ""); err != nil {
+				return err
+			}
+
+			return deletePair(args[0].(*object.Hash).Copy(), args[1])
+		},
+	},
+	"deleteInPlace": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("deleteInPlace", args, 2, object.HASH_OBJ, ""); err != nil {
+				return err
+			}
+
+			if err := checkNotFrozen(args[0]); err != nil {
+				return err
+			}
+			return deletePair(args[0].(*object.Hash), args[1])
+		},
+	},
+	"merge": {
+		Fn: func(args ...object.Object) object.Object {
+			hashes, err := hashArgs("merge", args)
+			if err != nil {
+				return err
+			}
+
+			return mergeHashes(hashes[0].Copy(), hashes[1:])
+		},
+	},
+	"mergeInPlace": {
+		Fn: func(args ...object.Object) object.Object {
+			hashes, err := hashArgs("mergeInPlace", args)
+			if err != nil {
+				return err
+			}
+			if err := checkNotFrozen(hashes[0]); err != nil {
+				return err
+			}
+
+			return mergeHashes(hashes[0], hashes[1:])
+		},
+	},
+}
+
+func init() {
+	for name, builtin := range hashBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// hashArray returns an array of one element per pair of hash.
+func hashArray(hash *object.Hash, element func(object.HashPair) object.Object) *object.Array {
+	pairs := hash.Entries()
+	elements := make([]object.Object, len(pairs))
+	for i, pair := range pairs {
+		elements[i] = element(pair)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// hashArgs returns args, checking that there is at least one and that they
+// are all hashes.
+func hashArgs(name string, args []object.Object) ([]*object.Hash, *object.Error) {
+	if len(args) < 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1 or more",
+			len(args))
+	}
+
+	hashes := make([]*object.Hash, len(args))
+	for i, arg := range args {
+		hash, ok := arg.(*object.Hash)
+		if !ok {
+			return nil, newError("argument %d to `%s` must be HASH, got %s",
+				i+1, name, arg.Type())
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
+
+func hashKey(key object.Object) (object.Hashable, *object.Error) {
+	hashable, ok := object.AsHashable(key)
+	if !ok {
+		return nil, newError("unusable as hash key: %s", key.Type())
+	}
+	return hashable, nil
+}
+
+func setPair(hash *object.Hash, key, value object.Object) *object.Error {
+	hashable, err := hashKey(key)
+	if err != nil {
+		return err
+	}
+	hash.Set(hashable, value)
+	return nil
+}
+
+// deletePair removes key from hash and returns hash.
+func deletePair(hash *object.Hash, key object.Object) object.Object {
+	hashable, err := hashKey(key)
+	if err != nil {
+		return err
+	}
+	hash.Delete(hashable)
+	return hash
+}
+
+// mergeHashes adds the pairs of others to hash and returns hash. A key in
+// more than one hash gets the value of the last one.
+func mergeHashes(hash *object.Hash, others []*object.Hash) *object.Hash {
+	for _, other := range others {
+		for _, pair := range other.Entries() {
+			hash.Set(pair.Key, pair.Value)
+		}
+	}
+	return hash
+}
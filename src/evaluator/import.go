@@ -0,0 +1,147 @@
+package evaluator
+
+import (
+	"gorilla/ast"
+	"gorilla/lexer"
+	"gorilla/object"
+	"gorilla/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SourceExt is the extension added to import paths that don't have one.
+const SourceExt = ".gor"
+
+// evalImport evaluates the module an import refers to, the first time it
+// is imported in the session of env, and returns it.
+func evalImport(node *ast.ImportStatement, env *object.Environment) object.Object {
+	session := env.Session()
+	module, err := Import(session, node.Path.Value, node.Token.Pos.Filename,
+		func(path string, program *ast.Program) (object.Object, *object.Error) {
+			moduleEnv := object.NewSessionEnvironment(session)
+			if err, ok := Eval(program, moduleEnv).(*object.Error); ok {
+				return nil, err
+			}
+			return &object.Module{Name: ModuleName(path), Path: path, Env: moduleEnv}, nil
+		})
+	if err != nil {
+		return err
+	}
+	return module
+}
+
+// RunModule runs the parsed program of the module file at path and returns
+// the namespace the module is bound to.
+type RunModule func(path string, program *ast.Program) (object.Object, *object.Error)
+
+// Import returns the module importPath refers to, where importer is the
+// file that imports it. The first time the module is imported in session
+// its file is read, parsed and given to run; later imports get the same
+// namespace. Both engines import this way and differ only in run.
+func Import(session *object.Session, importPath, importer string, run RunModule) (object.Object, *object.Error) {
+	path, ok := ResolveImport(importPath, importer, session.SearchPath)
+	if !ok {
+		return nil, newError("module not found: %s", importPath)
+	}
+
+	if module, ok := session.Modules[path]; ok {
+		return module, nil
+	}
+
+	loading := session.Loading
+	for i, p := range loading {
+		if p == path {
+			cycle := append(loading[i:len(loading):len(loading)], path)
+			return nil, newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newError("could not read module %s: %s", importPath, err)
+	}
+
+	l := lexer.NewWithFile(string(dat), path)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, newError("parser errors in module %s:\n\t%s",
+			importPath, strings.Join(p.Errors(), "\n\t"))
+	}
+
+	session.Loading = append(session.Loading, path)
+	module, runErr := run(path, program)
+	session.Loading = session.Loading[:len(session.Loading)-1]
+	if runErr != nil {
+		return nil, runErr
+	}
+
+	session.Modules[path] = module
+	return module, nil
+}
+
+// ResolveImport finds the file an import path refers to. Relative paths are
+// tried against the directory of the importing file first, then against
+// every directory of searchPath.
+func ResolveImport(importPath, importer string, searchPath []string) (string, bool) {
+	if filepath.Ext(importPath) == "" {
+		importPath += SourceExt
+	}
+
+	var candidates []string
+	if filepath.IsAbs(importPath) {
+		candidates = []string{importPath}
+	} else {
+		candidates = append(candidates, filepath.Join(filepath.Dir(importer), importPath))
+		for _, dir := range searchPath {
+			candidates = append(candidates, filepath.Join(dir, importPath))
+		}
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		abs, err := filepath.Abs(candidate)
+		if err != nil {
+			return "", false
+		}
+		return abs, true
+	}
+	return "", false
+}
+
+// ImportName is the name an import binds: the alias, or else the file name
+// without its extension.
+func ImportName(node *ast.ImportStatement) string {
+	if node.Alias != nil {
+		return node.Alias.Value
+	}
+	return ModuleName(node.Path.Value)
+}
+
+// ModuleName is the name a module file is known by: its base name without
+// the extension.
+func ModuleName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	module, ok := obj.(*object.Module)
+	if !ok {
+		return newError("member access not supported: %s", obj.Type())
+	}
+
+	if strings.HasPrefix(name, "_") {
+		return newError("%s is not exported by module %s", name, module.Name)
+	}
+
+	val, ok := module.Env.Get(name)
+	if !ok {
+		return newError("module %s has no member %s", module.Name, name)
+	}
+	return val
+}
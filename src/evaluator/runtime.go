@@ -0,0 +1,66 @@
+package evaluator
+
+import (
+	"gorilla/object"
+	"sort"
+)
+
+// The functions in this file give other execution engines, like the
+// bytecode VM, the exact semantics of the tree-walking evaluator, so that
+// a program produces the same values and errors whichever engine runs it.
+
+// EvalInfix applies a binary operator other than && and || to two values.
+func EvalInfix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right, "")
+}
+
+// EvalPrefix applies the prefix operator ! or - to a value.
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right, "")
+}
+
+// EvalIndex returns left[index].
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// EvalIndexAssignment performs left[index] = val and returns val.
+func EvalIndexAssignment(left, index, val object.Object) object.Object {
+	return evalIndexAssignment(left, index, val)
+}
+
+// Interpolate joins the evaluated parts of a template literal.
+func Interpolate(parts []object.Object) object.Object {
+	return interpolate(parts)
+}
+
+// IsTruthy reports whether obj counts as true in a condition.
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// LookupBuiltin returns the builtin function called name.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// BuiltinNames returns the names of all builtin functions, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ThrownError returns the error a throw statement raises for val.
+func ThrownError(val object.Object) *object.Error {
+	return &object.Error{Message: thrownMessage(val), Value: val}
+}
+
+// CaughtValue returns what a catch clause binds for err.
+func CaughtValue(err *object.Error) object.Object {
+	return caughtValue(err)
+}
@@ -0,0 +1,352 @@
+package evaluator
+
+import (
+	"fmt"
+	"gorilla/object"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxRepeatLength is the size in bytes of the longest string repeat builds.
+// A longer one could take all the memory, or fail to be allocated at all.
+const maxRepeatLength = 1 << 30
+
+// The string builtins count positions and lengths in chars, like indexing
+// and len do.
+var stringBuiltins = map[string]*object.Builtin{
+	"split": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("split", args, 1, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			var parts []string
+			if len(args) == 1 {
+				parts = strings.Fields(s)
+			} else {
+				parts = strings.Split(s, args[1].(*object.String).Value)
+			}
+			return stringArray(parts)
+		},
+	},
+	"join": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("join", args, 1, object.ARRAY_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			parts := make([]string, len(elements))
+			for i, el := range elements {
+				parts[i] = el.Inspect()
+			}
+
+			sep := ""
+			if len(args) == 2 {
+				sep = args[1].(*object.String).Value
+			}
+			return &object.String{Value: strings.Join(parts, sep)}
+		},
+	},
+	"trim": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("trim", args, 1, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			if len(args) == 1 {
+				return &object.String{Value: strings.TrimSpace(s)}
+			}
+			return &object.String{Value: strings.Trim(s, args[1].(*object.String).Value)}
+		},
+	},
+	"upper": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("upper", args, 1, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+		},
+	},
+	"lower": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("lower", args, 1, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+		},
+	},
+	"replace": {
+		Fn: func(args ...object.Object) object.Object {
+			err := checkArgs("replace", args, 3, object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ)
+			if err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			old := args[1].(*object.String).Value
+			new := args[2].(*object.String).Value
+			return &object.String{Value: strings.ReplaceAll(s, old, new)}
+		},
+	},
+	"contains": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("contains", args, 2, "", ""); err != nil {
+				return err
+			}
+
+			if arr, ok := args[0].(*object.Array); ok {
+				return nativeBoolToBooleanObject(indexOf(arr, args[1]) >= 0)
+			}
+			s, sub, err := stringAndSubstring("contains", args)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.Contains(s, sub))
+		},
+	},
+	"startsWith": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("startsWith", args, 2, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			s, prefix := args[0].(*object.String).Value, args[1].(*object.String).Value
+			return nativeBoolToBooleanObject(strings.HasPrefix(s, prefix))
+		},
+	},
+	"endsWith": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("endsWith", args, 2, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			s, suffix := args[0].(*object.String).Value, args[1].(*object.String).Value
+			return nativeBoolToBooleanObject(strings.HasSuffix(s, suffix))
+		},
+	},
+	"indexOf": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("indexOf", args, 2, "", ""); err != nil {
+				return err
+			}
+
+			if arr, ok := args[0].(*object.Array); ok {
+				return &object.Integer{Value: int64(indexOf(arr, args[1]))}
+			}
+			s, sub, err := stringAndSubstring("indexOf", args)
+			if err != nil {
+				return err
+			}
+			i := strings.Index(s, sub)
+			if i < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+		},
+	},
+	"substr": {
+		Fn: func(args ...object.Object) object.Object {
+			err := checkArgs("substr", args, 2, object.STRING_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ)
+			if err != nil {
+				return err
+			}
+
+			chars := []rune(args[0].(*object.String).Value)
+			start := args[1].(*object.Integer).Value
+			if start < 0 {
+				return newError("`substr` start must not be negative, got %d", start)
+			}
+			start = min(start, int64(len(chars)))
+
+			end := int64(len(chars))
+			if len(args) == 3 {
+				length := args[2].(*object.Integer).Value
+				if length < 0 {
+					return newError("`substr` length must not be negative, got %d", length)
+				}
+				// start+length could overflow
+				end = start + min(length, end-start)
+			}
+			return &object.String{Value: string(chars[start:end])}
+		},
+	},
+	"repeat": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("repeat", args, 2, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+
+			str := args[0].(*object.String).Value
+			count := args[1].(*object.Integer).Value
+			if count < 0 {
+				return newError("`repeat` count must not be negative, got %d", count)
+			}
+			if len(str) > 0 && count > maxRepeatLength/int64(len(str)) {
+				return newError("`repeat` result would be longer than %d bytes", maxRepeatLength)
+			}
+			return &object.String{Value: strings.Repeat(str, int(count))}
+		},
+	},
+	"format": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want=1 or more",
+					len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument 1 to `format` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			return formatString(args[0].(*object.String).Value, args[1:])
+		},
+	},
+	"chars": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("chars", args, 1, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			return stringArray(strings.Split(args[0].(*object.String).Value, ""))
+		},
+	},
+}
+
+func init() {
+	for name, builtin := range stringBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// checkArgs checks a call to the builtin name. It takes required up to
+// len(types) arguments, the one at position i of type types[i]. An empty
+// type takes any argument.
+func checkArgs(name string, args []object.Object, required int, types ...object.ObjectType) *object.Error {
+	if len(args) < required || len(args) > len(types) {
+		want := strconv.Itoa(required)
+		for n := required + 1; n <= len(types); n++ {
+			if n == len(types) {
+				want += " or " + strconv.Itoa(n)
+			} else {
+				want += ", " + strconv.Itoa(n)
+			}
+		}
+		return newError("wrong number of arguments. got=%d, want=%s", len(args), want)
+	}
+
+	for i, arg := range args {
+		if types[i] == "" || arg.Type() == types[i] {
+			continue
+		}
+		if len(types) == 1 {
+			return newError("argument to `%s` must be %s, got %s", name, types[i], arg.Type())
+		}
+		return newError("argument %d to `%s` must be %s, got %s", i+1, name, types[i], arg.Type())
+	}
+	return nil
+}
+
+// stringAndSubstring returns the arguments of a call to contains or indexOf
+// on a string.
+func stringAndSubstring(name string, args []object.Object) (string, string, *object.Error) {
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return "", "", newError("argument 1 to `%s` must be STRING or ARRAY, got %s",
+			name, args[0].Type())
+	}
+	sub, ok := args[1].(*object.String)
+	if !ok {
+		return "", "", newError("argument 2 to `%s` must be STRING, got %s",
+			name, args[1].Type())
+	}
+	return s.Value, sub.Value, nil
+}
+
+func stringArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// formatString formats args the way the verbs in format say, like Go's
+// fmt.Sprintf does. %d, %x, %X, %o, %b and %c take an INTEGER, %f, %e, %E,
+// %g and %G a number, %t a BOOLEAN, and %s, %q and %v any value, written
+// the way string() does. %% is a percent sign.
+func formatString(format string, args []object.Object) object.Object {
+	var out strings.Builder
+	next := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		// Flags, width and precision go to fmt as they are
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0.123456789", format[i]) >= 0 {
+			i++
+		}
+		if i == len(format) {
+			return newError("format %q ends in the middle of a verb", format)
+		}
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size - 1
+		spec := format[start : i+1]
+
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next == len(args) {
+			return newError("not enough arguments for format %q", format)
+		}
+		arg := args[next]
+		next++
+
+		value, err := formatValue(verb, arg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&out, spec, value)
+	}
+
+	if next < len(args) {
+		return newError("too many arguments for format %q: got %d, want %d",
+			format, len(args), next)
+	}
+	return &object.String{Value: out.String()}
+}
+
+// formatValue returns the Go value to format arg with for the verb.
+func formatValue(verb rune, arg object.Object) (interface{}, *object.Error) {
+	switch verb {
+	case 'd', 'x', 'X', 'o', 'b', 'c':
+		if integer, ok := arg.(*object.Integer); ok {
+			return integer.Value, nil
+		}
+		return nil, newError("%%%c in format needs INTEGER, got %s", verb, arg.Type())
+	case 'f', 'e', 'E', 'g', 'G':
+		if isNumber(arg) {
+			return toFloat(arg), nil
+		}
+		return nil, newError("%%%c in format needs INTEGER or FLOAT, got %s", verb, arg.Type())
+	case 't':
+		if boolean, ok := arg.(*object.Boolean); ok {
+			return boolean.Value, nil
+		}
+		return nil, newError("%%t in format needs BOOLEAN, got %s", arg.Type())
+	case 's', 'q', 'v':
+		return arg.Inspect(), nil
+	}
+	return nil, newError("unknown verb %%%c in format", verb)
+}
@@ -0,0 +1,481 @@
+// Package format lays out Gorilla source code in the standard style: four
+// spaces of indentation, one statement per line, spaces around binary
+// operators and only the parentheses the grouping needs. Comments and
+// single blank lines between statements are kept.
+package format
+
+import (
+	"errors"
+	"gorilla/ast"
+	"gorilla/lexer"
+	"gorilla/parser"
+	"gorilla/token"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Indent is the indentation of each level of nesting.
+const Indent = "    "
+
+// Source returns src in the standard layout. The file name is only used in
+// the parser errors returned when src doesn't parse.
+func Source(src, filename string) (string, error) {
+	p := parser.New(lexer.NewWithFile(src, filename))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	pr := newPrinter(src, filename)
+	pr.statements(program.Statements, endOfFile)
+	if pr.buf.Len() == 0 {
+		return "", nil
+	}
+	pr.buf.WriteString("\n")
+	return pr.buf.String(), nil
+}
+
+// endOfFile is a position after every token.
+var endOfFile = token.Position{Line: math.MaxInt}
+
+// before reports whether position a comes before b.
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+type comment struct {
+	pos   token.Position
+	end   int // line the comment ends on, after pos for a /* */ comment
+	text  string
+	block bool // a /* */ comment, which can be followed by code on its line
+}
+
+// printer writes the formatted program. The syntax tree has no comments,
+// so the printer also lexes the source to find those.
+type printer struct {
+	src   string
+	lines []int // offset in src of the start of each line
+
+	comments []comment
+	next     int // first comment not printed yet
+
+	buf    strings.Builder
+	indent int
+}
+
+func newPrinter(src, filename string) *printer {
+	p := &printer{
+		src:   src,
+		lines: []int{0},
+	}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			p.lines = append(p.lines, i+1)
+		}
+	}
+
+	l := lexer.NewWithFile(src, filename)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.COMMENT {
+			p.comments = append(p.comments, comment{
+				pos:   tok.Pos,
+				end:   tok.End.Line,
+				text:  strings.TrimRight(tok.Literal, " \t\r"),
+				block: strings.HasPrefix(tok.Literal, "/*"),
+			})
+		}
+	}
+	return p
+}
+
+// offset returns the offset in src of pos, whose column counts chars.
+func (p *printer) offset(pos token.Position) int {
+	offset := p.lines[pos.Line-1]
+	for column := 1; column < pos.Column && offset < len(p.src); column++ {
+		_, size := utf8.DecodeRuneInString(p.src[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// source returns the text of tok as written.
+func (p *printer) source(tok token.Token) string {
+	return p.src[p.offset(tok.Pos):p.offset(tok.End)]
+}
+
+func (p *printer) write(s string) {
+	p.buf.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.write("\n" + strings.Repeat(Indent, p.indent))
+}
+
+// startLine starts the line of something found at the given line of the
+// source, leaving a blank line if there is one after the last line printed.
+func (p *printer) startLine(line, last int) {
+	if p.buf.Len() == 0 {
+		return
+	}
+	if last > 0 && line > last+1 {
+		p.write("\n")
+	}
+	p.newline()
+}
+
+// hasCommentBefore reports whether a comment not printed yet comes before
+// pos.
+func (p *printer) hasCommentBefore(pos token.Position) bool {
+	return p.next < len(p.comments) && before(p.comments[p.next].pos, pos)
+}
+
+// commentsBefore prints the comments before pos on lines of their own.
+func (p *printer) commentsBefore(pos token.Position, last *int) {
+	for p.hasCommentBefore(pos) {
+		c := p.comments[p.next]
+		p.startLine(c.pos.Line, *last)
+		p.write(c.text)
+		*last = c.end
+		p.next++
+	}
+}
+
+// trailingComment prints a comment before limit that is on the given line
+// of the source at the end of the line printed. It returns the line of the
+// source where what it printed ends.
+func (p *printer) trailingComment(limit token.Position, line int) int {
+	if p.hasCommentBefore(limit) && p.comments[p.next].pos.Line == line {
+		c := p.comments[p.next]
+		p.write(" " + c.text)
+		p.next++
+		return c.end
+	}
+	return line
+}
+
+// inlineComments prints the comments before pos where the printer is, in
+// the middle of an expression. Code can't follow a # comment on its line,
+// so the expression goes on in the next one.
+func (p *printer) inlineComments(pos token.Position) {
+	for p.hasCommentBefore(pos) {
+		c := p.comments[p.next]
+		p.write(c.text)
+		if c.block {
+			p.write(" ")
+		} else {
+			p.indent++
+			p.newline()
+			p.indent--
+		}
+		p.next++
+	}
+}
+
+// statements prints stmts one per line, with the comments before end among
+// them. A comment on the line a statement ends stays at its end.
+func (p *printer) statements(stmts []ast.Statement, end token.Position) {
+	last := 0 // line of the source where the last thing printed ends
+
+	for i, s := range stmts {
+		p.commentsBefore(s.Pos(), &last)
+		p.startLine(s.Pos().Line, last)
+
+		limit := end
+		var next ast.Statement
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+			limit = next.Pos()
+		}
+
+		p.statement(s)
+		if needsSemicolon(s, next) {
+			p.write(";")
+		}
+
+		last = p.trailingComment(limit, s.End().Line)
+	}
+
+	p.commentsBefore(end, &last)
+}
+
+// needsSemicolon reports whether s is followed by a semicolon. Statements
+// ending in a block only need one when the next statement would otherwise
+// continue their expression.
+func needsSemicolon(s, next ast.Statement) bool {
+	es, ok := s.(*ast.ExpressionStatement)
+	if !ok {
+		return true
+	}
+	switch es.Expression.(type) {
+	case *ast.IfExpression, *ast.WhileExpression, *ast.ForExpression, *ast.TryExpression:
+		nextExp, ok := next.(*ast.ExpressionStatement)
+		return ok && continues(nextExp.Expression)
+	}
+	return true
+}
+
+// continues reports whether the printed e starts with a token that can
+// also continue the expression before it: a parenthesis, a bracket or a
+// minus sign.
+func continues(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.ArrayLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return e.Operator == "-"
+	case *ast.InfixExpression:
+		return precedence(e.Left) < precedence(e) || continues(e.Left)
+	case *ast.AssignExpression:
+		return continues(e.Target)
+	case *ast.CallExpression:
+		return precedence(e.Function) < parser.CALL || continues(e.Function)
+	case *ast.IndexExpression:
+		return precedence(e.Left) < parser.CALL || continues(e.Left)
+	case *ast.MemberExpression:
+		return precedence(e.Object) < parser.CALL || continues(e.Object)
+	}
+	return false
+}
+
+func (p *printer) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.write("let " + s.Name.Value + " = ")
+		p.expression(s.Value)
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(s.ReturnValue)
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expression(s.Value)
+	case *ast.ImportStatement:
+		p.write("import " + p.source(s.Path.Token))
+		if s.Alias != nil {
+			p.write(" as " + s.Alias.Value)
+		}
+	case *ast.BreakStatement:
+		p.write("break")
+	case *ast.ContinueStatement:
+		p.write("continue")
+	case *ast.ExpressionStatement:
+		p.expression(s.Expression)
+	case *ast.BlockStatement:
+		p.block(s)
+	}
+}
+
+// block prints a block on lines of its own, unless it was written on one
+// line and holds at most one statement.
+func (p *printer) block(block *ast.BlockStatement) {
+	end := block.Rbrace.Pos
+	stmts := block.Statements
+
+	switch {
+	case p.hasCommentBefore(end):
+	case len(stmts) == 0:
+		p.write("{}")
+		return
+	case len(stmts) == 1 && end.Line == block.Token.Pos.Line:
+		p.write("{ ")
+		p.statement(stmts[0])
+		p.write(" }")
+		return
+	}
+
+	p.write("{")
+	p.indent++
+	p.statements(block.Statements, end)
+	p.indent--
+	p.newline()
+	p.write("}")
+}
+
+// precedence returns how tightly e holds together, as the parser's
+// precedence of its operator.
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.AssignExpression:
+		return parser.ASSIGNMENT
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression, *ast.MemberExpression:
+		return parser.CALL
+	}
+	return parser.INDEX
+}
+
+// operand prints e, in parentheses if parens is set.
+func (p *printer) operand(e ast.Expression, parens bool) {
+	if parens {
+		p.write("(")
+	}
+	p.expression(e)
+	if parens {
+		p.write(")")
+	}
+}
+
+func (p *printer) expression(e ast.Expression) {
+	p.inlineComments(e.Pos())
+
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		p.write(e.Token.Literal)
+	case *ast.FloatLiteral:
+		p.write(e.Token.Literal)
+	case *ast.Boolean:
+		p.write(e.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(p.source(e.Token))
+	case *ast.TemplateLiteral:
+		// The source of a text part takes in the ${ and } around it
+		for i, part := range e.Parts {
+			if i%2 == 1 {
+				p.expression(part)
+			} else {
+				p.write(p.source(part.(*ast.StringLiteral).Token))
+			}
+		}
+
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.operand(e.Right, precedence(e.Right) < parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := precedence(e)
+		p.operand(e.Left, precedence(e.Left) < prec)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, precedence(e.Right) <= prec)
+	case *ast.AssignExpression:
+		p.expression(e.Target)
+		p.write(" " + e.Operator + " ")
+		p.expression(e.Value)
+
+	case *ast.CallExpression:
+		p.operand(e.Function, precedence(e.Function) < parser.CALL)
+		p.write("(")
+		p.expressions(e.Token.Pos, e.Rparen.Pos, e.Arguments)
+		p.write(")")
+	case *ast.IndexExpression:
+		p.operand(e.Left, precedence(e.Left) < parser.CALL)
+		p.write("[")
+		p.expression(e.Index)
+		p.write("]")
+	case *ast.MemberExpression:
+		p.operand(e.Object, precedence(e.Object) < parser.CALL)
+		p.write("." + e.Member.Value)
+
+	case *ast.ArrayLiteral:
+		p.write("[")
+		p.expressions(e.Token.Pos, e.Rbracket.Pos, e.Elements)
+		p.write("]")
+	case *ast.HashLiteral:
+		p.hash(e)
+
+	case *ast.FunctionLiteral:
+		params := make([]string, len(e.Parameters))
+		for i, param := range e.Parameters {
+			params[i] = param.Value
+		}
+		p.write("fn(" + strings.Join(params, ", ") + ") ")
+		p.block(e.Body)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.WhileExpression:
+		p.write("while (")
+		p.expression(e.Condition)
+		p.write(") ")
+		p.block(e.Body)
+	case *ast.ForExpression:
+		p.write("for (")
+		if e.Key != nil {
+			p.write(e.Key.Value + ", ")
+		}
+		p.write(e.Value.Value + " in ")
+		p.expression(e.Iterable)
+		p.write(") ")
+		p.block(e.Body)
+	case *ast.TryExpression:
+		p.write("try ")
+		p.block(e.Block)
+		if e.Catch != nil {
+			p.write(" catch ")
+			if e.CatchParam != nil {
+				p.write("(" + e.CatchParam.Value + ") ")
+			}
+			p.block(e.Catch)
+		}
+		if e.Finally != nil {
+			p.write(" finally ")
+			p.block(e.Finally)
+		}
+	}
+}
+
+// list prints n comma-separated items between the brackets at open and
+// end, the i-th starting at pos(i) and ending at endOf(i). They go one per
+// line, with the comments among them, if the first one didn't start on the
+// line of open or there are comments.
+func (p *printer) list(open, end token.Position, n int, pos, endOf func(i int) token.Position, item func(i int)) {
+	if !p.hasCommentBefore(end) && (n == 0 || pos(0).Line == open.Line) {
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				p.write(", ")
+			}
+			item(i)
+		}
+		return
+	}
+
+	p.indent++
+	last := 0
+	for i := 0; i < n; i++ {
+		p.commentsBefore(pos(i), &last)
+		p.startLine(pos(i).Line, last)
+
+		limit := end
+		if i+1 < n {
+			limit = pos(i + 1)
+		}
+		item(i)
+		if i < n-1 {
+			p.write(",")
+		}
+
+		last = p.trailingComment(limit, endOf(i).Line)
+	}
+	p.commentsBefore(end, &last)
+	p.indent--
+	p.newline()
+}
+
+func (p *printer) expressions(open, end token.Position, elements []ast.Expression) {
+	p.list(open, end, len(elements),
+		func(i int) token.Position { return elements[i].Pos() },
+		func(i int) token.Position { return elements[i].End() },
+		func(i int) { p.expression(elements[i]) })
+}
+
+func (p *printer) hash(hash *ast.HashLiteral) {
+	p.write("{")
+	p.list(hash.Token.Pos, hash.Rbrace.Pos, len(hash.Keys),
+		func(i int) token.Position { return hash.Keys[i].Pos() },
+		func(i int) token.Position { return hash.Pairs[hash.Keys[i]].End() },
+		func(i int) {
+			p.expression(hash.Keys[i])
+			p.write(": ")
+			p.expression(hash.Pairs[hash.Keys[i]])
+		})
+	p.write("}")
+}
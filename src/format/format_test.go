@@ -0,0 +1,262 @@
+package format
+
+import (
+	"gorilla/lexer"
+	"gorilla/parser"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"let x = (1 + 2) * 3;", "let x = (1 + 2) * 3;\n"},
+		{"((a + b) + c) - (d - e)", "a + b + c - (d - e);\n"},
+		{"-(a + b); !!x; -(-x)", "-(a + b);\n!!x;\n--x;\n"},
+		{"(-a)[0]; (f)(1)[2].x; (a + b)(c)", "(-a)[0];\nf(1)[2].x;\n(a + b)(c);\n"},
+		{"a = b = c; x += (y = 1)", "a = b = c;\nx += y = 1;\n"},
+		{"let a = 1; let b = 2", "let a = 1;\nlet b = 2;\n"},
+		{`let s = 'a\tb' + "c"`, "let s = 'a\\tb' + \"c\";\n"},
+		{`let größe="日本\u{1F98D}"+'\'' # ü`, "let größe = \"日本\\u{1F98D}\" + '\\''; # ü\n"},
+		{`"a ${x+1} \${b} ${ f("${y}") }c"`, "\"a ${x + 1} \\${b} ${f(\"${y}\")}c\";\n"},
+		{"let s = \"\"\"\n  raw ${x}\n\"\"\" +1", "let s = \"\"\"\n  raw ${x}\n\"\"\" + 1;\n"},
+		{"let n = 2.5e3 + 10", "let n = 2.5e3 + 10;\n"},
+		{"[1,2,  3]; {\"a\":1,\"b\" : [2]}; {}", "[1, 2, 3];\n{\"a\": 1, \"b\": [2]};\n{};\n"},
+		{"let f = fn(a,b) { a + b }", "let f = fn(a, b) { a + b };\n"},
+		{"let f = fn() {}; let g = fn() {\n}", "let f = fn() {};\nlet g = fn() {};\n"},
+		{"let f = fn(x) { let y = x; y }", "let f = fn(x) {\n    let y = x;\n    y;\n};\n"},
+		{"let f = fn(x) {\nreturn x\n}", "let f = fn(x) {\n    return x;\n};\n"},
+		{
+			"if (a) {\nb } else { c }",
+			"if (a) {\n    b;\n} else { c }\n",
+		},
+		{
+			"while (i < 3) { i += 1; if (i == 2) { break } }",
+			"while (i < 3) {\n    i += 1;\n    if (i == 2) { break }\n}\n",
+		},
+		{
+			"for (k, v in h) { puts(k) }\nfor (x in xs) {\ncontinue\n}",
+			"for (k, v in h) { puts(k) }\nfor (x in xs) {\n    continue;\n}\n",
+		},
+		{
+			"try { throw 1 } catch (e) { e } finally { x }\ntry {} catch {}",
+			"try { throw 1 } catch (e) { e } finally { x }\ntry {} catch {}\n",
+		},
+		{`import "lib/math" as m; m.pi`, "import \"lib/math\" as m;\nm.pi;\n"},
+
+		// Line breaks in literals and calls
+		{
+			"let a = [\n1,\n  2]; let b = [1,\n2]",
+			"let a = [\n    1,\n    2\n];\nlet b = [1, 2];\n",
+		},
+		{
+			"let h = {\n\"a\": fn(x) {\nx\n}, \"b\": 2,\n}",
+			"let h = {\n    \"a\": fn(x) {\n        x;\n    },\n    \"b\": 2\n};\n",
+		},
+		{
+			"f(\na, b)",
+			"f(\n    a,\n    b\n);\n",
+		},
+
+		// Blank lines
+		{
+			"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;\n",
+			"let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			"\n\nlet f = fn() {\n\n  a\n\n  b\n\n}\n\n",
+			"let f = fn() {\n    a;\n\n    b;\n};\n",
+		},
+
+		// Comments
+		{"# just a comment", "# just a comment\n"},
+		{
+			"# head\n\nlet a = 1 # one   \n# two\nlet b = 2\n\n# tail\n",
+			"# head\n\nlet a = 1; # one\n# two\nlet b = 2;\n\n# tail\n",
+		},
+		{
+			"let f = fn() { # start\n  # inside\n  1 # one\n  # end\n}",
+			"let f = fn() {\n    # start\n    # inside\n    1; # one\n    # end\n};\n",
+		},
+		{
+			"if (x) { # only a comment\n}",
+			"if (x) {\n    # only a comment\n}\n",
+		},
+		{
+			"let f = fn() {\nif (x) { y } # done\n}",
+			"let f = fn() {\n    if (x) { y } # done\n};\n",
+		},
+		{"## doc\nlet f = 1", "## doc\nlet f = 1;\n"},
+		{"/* header */ let z = 1 /* z */", "/* header */\nlet z = 1; /* z */\n"},
+		{"/* a\n   b */\nlet x = 1\n/* c\n */\n\nlet y = 2", "/* a\n   b */\nlet x = 1;\n/* c\n */\n\nlet y = 2;\n"},
+		{"let x = 1 /* a\n b */\nlet y = 2", "let x = 1; /* a\n b */\nlet y = 2;\n"},
+		{"f(a, /* a\n */\n b)", "f(\n    a, /* a\n */\n    b\n);\n"},
+		{"x /* a */ + /* b */ 1", "x + /* a */ /* b */ 1;\n"},
+		{"let y = 1 + # why\n 2", "let y = 1 + # why\n    2;\n"},
+		{
+			"let a = [1, # one\n  2 /* two */, 3] # trailing",
+			"let a = [\n    1, # one\n    2, /* two */\n    3\n]; # trailing\n",
+		},
+		{"f(a, # first\n b)", "f(\n    a, # first\n    b\n);\n"},
+		{
+			"let h = {\"a\": 1, # a\n/* b */ \"b\": 2}",
+			"let h = {\n    \"a\": 1, # a\n    /* b */\n    \"b\": 2\n};\n",
+		},
+		{"[ # c\n]", "[\n    # c\n];\n"},
+		{
+			"let a = [f(\n1), # one\n{\"k\": [\n2]}] # a",
+			"let a = [\n    f(\n        1\n    ), # one\n    {\"k\": [\n        2\n    ]}\n]; # a\n",
+		},
+		{"let s = \"\"\"\nraw\n\"\"\" # s\nlet t = 1", "let s = \"\"\"\nraw\n\"\"\"; # s\nlet t = 1;\n"},
+		{"if (x) { 1 } # c\n else { 2 }", "if (x) { 1 } else {\n    # c\n    2;\n}\n"},
+
+		// Statements ending in a block keep a semicolon when the next one
+		// would continue their expression
+		{"if (x) { 1 }; [2]", "if (x) { 1 };\n[2];\n"},
+		{"if (x) { 1 }; -2", "if (x) { 1 };\n-2;\n"},
+		{"if (x) { 1 }; (a + b) * c", "if (x) { 1 };\n(a + b) * c;\n"},
+		{"if (x) { 1 }; a", "if (x) { 1 }\na;\n"},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source(tt.input, "")
+		if err != nil {
+			t.Errorf("Source(%q) failed: %s", tt.input, err)
+			continue
+		}
+		if formatted != tt.expected {
+			t.Errorf("Source(%q) wrong.\nwant=%q\ngot =%q", tt.input, tt.expected, formatted)
+			continue
+		}
+
+		testEquivalent(t, tt.input, formatted)
+
+		again, err := Source(formatted, "")
+		if err != nil || again != formatted {
+			t.Errorf("formatting %q again changed it. got=%q (%v)", formatted, again, err)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 1;", "main.gor:1:5: "},
+		{"let s = \"abc", "main.gor:1:9: string not terminated"},
+		{"let s = \"a ${b} c", "main.gor:1:9: string not terminated"},
+	}
+
+	for _, tt := range tests {
+		_, err := Source(tt.input, "main.gor")
+		if err == nil {
+			t.Errorf("no error for %q", tt.input)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestSourceProgram(t *testing.T) {
+	input := `# Fibonacci numbers
+let fib = fn(n) {
+  if (n < 2) { return n }
+  fib(n-1)+fib(n - 2)   # the slow way
+};
+
+let memo = {};let fast = fn(n) {
+    if (n < 2) { return n; }
+    let key = string(n);
+    try { return memo[key] } catch { }
+    memo[key] = fast(n - 1) + fast(n - 2)
+  ; memo[key]
+}
+let results = map([1, 2, 3], fn(x) { fast(x) }
+)
+puts(results);
+`
+	expected := `# Fibonacci numbers
+let fib = fn(n) {
+    if (n < 2) { return n }
+    fib(n - 1) + fib(n - 2); # the slow way
+};
+
+let memo = {};
+let fast = fn(n) {
+    if (n < 2) { return n }
+    let key = string(n);
+    try { return memo[key] } catch {}
+    memo[key] = fast(n - 1) + fast(n - 2);
+    memo[key];
+};
+let results = map([1, 2, 3], fn(x) { fast(x) });
+puts(results);
+`
+
+	formatted, err := Source(input, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted != expected {
+		t.Errorf("wrong formatting.\nwant=%q\ngot =%q", expected, formatted)
+	}
+	testEquivalent(t, input, formatted)
+}
+
+// testEquivalent checks that formatted parses to the same program as
+// input.
+func testEquivalent(t *testing.T, input, formatted string) {
+	t.Helper()
+
+	parse := func(src string) string {
+		p := parser.New(lexer.New(src))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("%q doesn't parse: %v", src, p.Errors())
+		}
+		return program.String()
+	}
+
+	if want, got := parse(input), parse(formatted); want != got {
+		t.Errorf("formatting %q changed the program.\nwant=%q\ngot =%q", input, want, got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		old, new string
+		expected string
+	}{
+		{"a\n", "a\n", ""},
+		{
+			"a\nb\nc\n", "a\nx\nc\n",
+			"--- f.orig\n+++ f\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			"", "a\n",
+			"--- f.orig\n+++ f\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			"a", "a\n",
+			"--- f.orig\n+++ f\n@@ -1,1 +1,1 @@\n-a\n\\ No newline at end of file\n+a\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			"--- f.orig\n+++ f\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n" +
+				"@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Diff("f", tt.old, tt.new); got != tt.expected {
+			t.Errorf("Diff(%q, %q) wrong.\nwant=%q\ngot =%q", tt.old, tt.new, tt.expected, got)
+		}
+	}
+}
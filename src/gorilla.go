@@ -1,37 +1,82 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
+	"gorilla/ast"
 	"gorilla/debug"
-	"gorilla/evaluator"
+	"gorilla/format"
 	"gorilla/lexer"
 	"gorilla/object"
 	"gorilla/parser"
+	"gorilla/repl"
+	"gorilla/token"
 	"io"
 	"os"
+	"slices"
+	"strings"
+	"time"
 )
 
-const PROMPT = ">> "
-const GORILLA_FACE = "🦍"
+// maxTraceFrames is the number of innermost and of outermost frames shown
+// of a long stack trace.
+const maxTraceFrames = 10
 
-func check(e error) {
-	if e != nil {
-		fmt.Println(e)
-		os.Exit(1)
-	}
-}
+// Exit codes. A script can also choose its own with exit().
+const (
+	exitOK    = 0
+	exitError = 1 // parser errors, uncaught runtime errors, unreadable files
+	exitUsage = 2 // wrong command-line arguments
+)
+
+const usage = `Usage:
+  gorilla [flags] [file.gor [args...]]
+  gorilla [flags] -e code [args...]
+  gorilla <command> [flags] [arguments]
+
+Commands:
+  run file.gor [args...]   run a file; "-" reads the program from stdin
+  repl                     start the interactive prompt
+  check file.gor...        parse files and report syntax errors
+  tokens file.gor          print the tokens of a file
+  ast file.gor             print the syntax tree of a file
+  fmt file.gor...          print files in the standard layout; with -w
+                           rewrite them, with -check print a diff of those
+                           that aren't formatted
+
+Without a command, gorilla runs the file it is given, or else starts the
+REPL. The arguments after the file or -e code are in the args array.
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, GORILLA_FACE)
+Exit status is 0 on success, 1 on a parser or uncaught runtime error, 2 on
+wrong arguments, or the code a script passes to exit().
+
+Flags:
+`
+
+func printRuntimeError(out io.Writer, err *object.Error) {
+	io.WriteString(out, repl.GORILLA_FACE)
 	io.WriteString(out, "\nWoops! We ran into some gorilla business here!\n")
-	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	io.WriteString(out, " runtime error:\n")
+	io.WriteString(out, "\t"+err.Inspect()+"\n")
+
+	// Deep recursion leaves thousands of frames, of which the innermost and
+	// outermost are the interesting ones
+	frames := err.Stack
+	if len(frames) > 2*maxTraceFrames {
+		frames = frames[:maxTraceFrames]
+	}
+	for _, frame := range frames {
+		io.WriteString(out, "\t    "+frame.String()+"\n")
+	}
+	if len(frames) < len(err.Stack) {
+		fmt.Fprintf(out, "\t    ... %d more frames\n", len(err.Stack)-2*maxTraceFrames)
+		for _, frame := range err.Stack[len(err.Stack)-maxTraceFrames:] {
+			io.WriteString(out, "\t    "+frame.String()+"\n")
+		}
 	}
 }
 
-func runRepl(in io.Reader, out io.Writer) {
+func runRepl(in io.Reader, out io.Writer, engine repl.Engine) {
 	fmt.Println("Gorilla 1.0.2 (main, Apr 30 2024)")
 	fmt.Println(`
 	⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⢀⣠⣤⣤⠀⠀⠀⠀
@@ -48,131 +93,294 @@ func runRepl(in io.Reader, out io.Writer) {
 	⠀⢠⣿⣿⣿⣿⣟⠀⠀⢿⣿⣿⣿⡄⠀⠀⢀⣿⣿⡟⠃⣸⣿⣿⣿⣿⡇⠀⠀⠀
 	⠀⠘⠛⠛⠛⠛⠛⠛⠀⠘⠛⠛⠛⠛⠓⠀⠛⠛⠛⠃⠘⠛⠛⠛⠛⠛⠃⠀⠀⠀`)
 
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	repl.Start(in, out, engine)
+}
 
-	// for {
-	// 	fmt.Printf(PROMPT)
-	// 	scanned := scanner.Scan()
+// options holds the command-line flags.
+type options struct {
+	engine   string
+	path     string
+	eval     string
+	trace    bool
+	maxDepth int
+	timeout  time.Duration
+	write    bool
+	check    bool
+}
 
-	// 	if !scanned {
-	// 		return
-	// 	}
+var commands = map[string]func(opts *options, args []string) int{
+	"run":    runCommand,
+	"repl":   replCommand,
+	"check":  checkCommand,
+	"tokens": tokensCommand,
+	"ast":    astCommand,
+	"fmt":    fmtCommand,
+}
 
-	// 	line := scanner.Text()
-	// 	l := lexer.New(line)
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
 
-	// 	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-	// 		fmt.Printf("%+v\n", tok)
-	// 	}
+	fs.StringVar(&opts.engine, "engine", "eval",
+		"how to run programs: eval walks the syntax tree, vm compiles to bytecode")
+	fs.StringVar(&opts.path, "path", os.Getenv("GORILLA_PATH"),
+		"directories to search for imported modules, separated by "+string(os.PathListSeparator))
+	fs.StringVar(&opts.eval, "e", "", "run `code` instead of a file")
+	fs.BoolVar(&opts.trace, "trace", false, "print every step of the evaluation")
+	fs.IntVar(&opts.maxDepth, "max-depth", object.DefaultMaxDepth, "maximum depth of nested function calls, 0 for no limit")
+	fs.DurationVar(&opts.timeout, "timeout", 0, "stop programs that run longer than this, 0 for no limit")
+	fs.BoolVar(&opts.write, "w", false, "fmt: write the result back to the files")
+	fs.BoolVar(&opts.check, "check", false, "fmt: print a diff of the files that aren't formatted and fail")
+	return fs
+}
 
-	// 	p := parser.New(lexer.New((line)))
-	// 	program := p.ParseProgram()
+func main() {
+	os.Exit(runMain(os.Args[1:]))
+}
 
-	// 	fmt.Printf("\nAST: %+v\n", program.String())
-	// }
+func runMain(args []string) int {
+	name := ""
+	command := defaultCommand
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			name, command = args[0], cmd
+			args = args[1:]
+		}
+	}
 
-	for {
-		fmt.Print(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+	var opts options
+	fs := newFlagSet("gorilla "+name, &opts)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
 		}
+		return exitUsage
+	}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+	debug.PRINTEVALUATION = opts.trace
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
-		}
+	if opts.timeout > 0 {
+		time.AfterFunc(opts.timeout, func() {
+			fmt.Fprintf(os.Stderr, "gorilla: time limit of %s exceeded\n", opts.timeout)
+			os.Exit(exitError)
+		})
+	}
 
-		// io.WriteString(out, program.String())
-		// io.WriteString(out, "\n")
+	return command(&opts, fs.Args())
+}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			if evaluated != evaluator.NULL {
-				io.WriteString(out, evaluated.Inspect())
-			}
-			io.WriteString(out, "\n")
-		}
+// usageError reports wrong command-line arguments.
+func usageError(format string, a ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "gorilla: "+format+"\n", a...)
+	fmt.Fprintln(os.Stderr, "Run 'gorilla -h' for usage.")
+	return exitUsage
+}
+
+func defaultCommand(opts *options, args []string) int {
+	if opts.eval == "" && len(args) == 0 {
+		return replCommand(opts, args)
+	}
+	return runCommand(opts, args)
+}
 
-		// p = parser.New(lexer.New((line)))
-		// program = p.ParseProgram()
-		// fmt.Printf("\nAST: %+v\n", program.String())
+func runCommand(opts *options, args []string) int {
+	engine, err := newEngine(opts)
+	if err != nil {
+		return usageError("%s", err)
+	}
 
-		// l = lexer.New(line)
-		// for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-		// 	fmt.Printf("%+v\n", tok)
-		// }
+	if opts.eval != "" {
+		return runSource(engine, opts.eval, "", args)
+	}
+	if len(args) == 0 {
+		return usageError("run needs a file to run")
+	}
 
+	src, err := readSource(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
 	}
+	return runSource(engine, src, args[0], args[1:])
 }
 
-func runFromFile(file string) {
-	dat, err := os.ReadFile(file)
-	check(err)
+func replCommand(opts *options, args []string) int {
+	if len(args) != 0 {
+		return usageError("repl takes no arguments")
+	}
+	engine, err := newEngine(opts)
+	if err != nil {
+		return usageError("%s", err)
+	}
 
-	env := object.NewEnvironment()
-	out := os.Stdout
+	runRepl(os.Stdin, os.Stdout, engine)
+	return exitOK
+}
 
-	line := string(dat)
-	l := lexer.New(line)
-	p := parser.New(l)
+func checkCommand(opts *options, args []string) int {
+	sources, status := commandSources(opts, args, -1)
+	if status != exitOK {
+		return status
+	}
 
-	program := p.ParseProgram()
-	if len(p.Errors()) != 0 {
-		printParserErrors(out, p.Errors())
-		os.Exit(1)
+	for _, src := range sources {
+		p := parser.New(lexer.NewWithFile(src.code, src.filename))
+		p.ParseProgram()
+		for _, msg := range p.Errors() {
+			fmt.Fprintln(os.Stderr, msg)
+			status = exitError
+		}
 	}
+	return status
+}
 
-	evaluated := evaluator.Eval(program, env)
-	if evaluated != nil {
-		evaluated.Inspect()
+func tokensCommand(opts *options, args []string) int {
+	sources, status := commandSources(opts, args, 1)
+	if status != exitOK {
+		return status
 	}
 
+	l := lexer.NewWithFile(sources[0].code, sources[0].filename)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Printf("%-6s %-10s %q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+	return exitOK
 }
 
-// func main() {
-// 	debug.PRINTEVALUATION = false
-// 	atleast_args := 1
+func astCommand(opts *options, args []string) int {
+	sources, status := commandSources(opts, args, 1)
+	if status != exitOK {
+		return status
+	}
 
-// 	idx := 0
-// 	for i, arg := range os.Args[1:] {
-// 		if arg == "--debug" {
-// 			debug.PRINTEVALUATION = true
-// 			idx = i + 1
-// 			atleast_args = 2
-// 		}
-// 	}
+	program, ok := parse(sources[0].code, sources[0].filename)
+	if !ok {
+		return exitError
+	}
+	fmt.Print(ast.Dump(program))
+	return exitOK
+}
 
-// 	// 			len	idx atleast
-// 	// g		1	0	1
-// 	// g -d		2	1	2
-// 	// g f		2	0	1
-// 	// g -d f	3	1	2
-// 	// g f -d	3	2	2
+func fmtCommand(opts *options, args []string) int {
+	if opts.write && opts.check {
+		return usageError("-w and -check can't be used together")
+	}
+	if opts.write && (opts.eval != "" || slices.Contains(args, "-")) {
+		return usageError("-w needs files to rewrite")
+	}
 
-// 	if len(os.Args) == atleast_args {
-// 		runRepl(os.Stdin, os.Stdout)
-// 	} else if len(os.Args) == atleast_args+1 {
-// 		runFromFile(os.Args[idx%2+1])
-// 	} else {
-// 		fmt.Println("Wrong arguments")
-// 	}
-// }
+	sources, status := commandSources(opts, args, -1)
+	if status != exitOK {
+		return status
+	}
 
-func main() {
-	debug.PRINTEVALUATION = false
+	for _, src := range sources {
+		formatted, err := format.Source(src.code, src.filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = exitError
+			continue
+		}
+
+		switch {
+		case opts.check:
+			if formatted != src.code {
+				name := src.filename
+				if name == "" {
+					name = "-e"
+				}
+				fmt.Print(format.Diff(name, src.code, formatted))
+				status = exitError
+			}
+		case opts.write:
+			if formatted != src.code {
+				if err := os.WriteFile(src.filename, []byte(formatted), 0o644); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					status = exitError
+				}
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+	return status
+}
+
+type source struct {
+	code     string
+	filename string
+}
+
+// commandSources returns the code a command works on: the -e code, or else
+// the files named by args, of which there must be max or, when max is -1,
+// at least one.
+func commandSources(opts *options, args []string, max int) ([]source, int) {
+	if opts.eval != "" {
+		if len(args) != 0 {
+			return nil, usageError("unexpected arguments with -e: %s", strings.Join(args, " "))
+		}
+		return []source{{code: opts.eval}}, exitOK
+	}
+
+	if len(args) == 0 || max != -1 && len(args) != max {
+		return nil, usageError("wrong number of files: %d", len(args))
+	}
+
+	var sources []source
+	for _, file := range args {
+		code, err := readSource(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, exitError
+		}
+		sources = append(sources, source{code: code, filename: file})
+	}
+	return sources, exitOK
+}
 
-	if len(os.Args) == 1 {
-		runRepl(os.Stdin, os.Stdout)
-	} else if len(os.Args) == 2 {
-		runFromFile(os.Args[1])
+// readSource returns the content of file, or of stdin when file is "-".
+func readSource(file string) (string, error) {
+	var dat []byte
+	var err error
+	if file == "-" {
+		dat, err = io.ReadAll(os.Stdin)
 	} else {
-		fmt.Println("Wrong arguments")
+		dat, err = os.ReadFile(file)
+	}
+	return string(dat), err
+}
+
+func parse(src, filename string) (*ast.Program, bool) {
+	p := parser.New(lexer.NewWithFile(src, filename))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		repl.PrintParserErrors(os.Stderr, p.Errors())
+		return nil, false
+	}
+	return program, true
+}
+
+// runSource runs a program with args bound to the array of script
+// arguments, and returns the exit status.
+func runSource(engine repl.Engine, src, filename string, args []string) int {
+	program, ok := parse(src, filename)
+	if !ok {
+		return exitError
+	}
+
+	scriptArgs := make([]object.Object, len(args))
+	for i, arg := range args {
+		scriptArgs[i] = &object.String{Value: arg}
+	}
+	engine.Define("args", &object.Array{Elements: scriptArgs})
+
+	evaluated := engine.Run(program)
+	if err, ok := evaluated.(*object.Error); ok {
+		printRuntimeError(os.Stderr, err)
+		return exitError
 	}
+	return exitOK
 }
@@ -0,0 +1,323 @@
+// Package gorilla embeds the Gorilla interpreter in Go programs.
+//
+//	interp := gorilla.NewInterpreter(gorilla.Options{})
+//	interp.SetGlobal("limit", 10)
+//	result, err := interp.Eval(`limit * 2`)
+//
+// Values cross the boundary as plain Go values: int64, float64, string,
+// bool, nil, []any and map[string]any. ToObject and FromObject do the
+// conversion and can be used directly by hosts that need object.Object.
+package gorilla
+
+import (
+	"fmt"
+	"gorilla/evaluator"
+	"gorilla/lexer"
+	"gorilla/object"
+	"gorilla/parser"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Options configures a new Interpreter.
+type Options struct {
+	// SearchPath lists the directories imports are looked up in after the
+	// directory of the importing file.
+	SearchPath []string
+
+	// MaxDepth limits how deeply function calls can nest before a call
+	// fails with a stack overflow error. Zero means object.DefaultMaxDepth.
+	MaxDepth int
+}
+
+// Interpreter evaluates Gorilla code in a global environment that lives
+// as long as the Interpreter, so bindings made by one call to Eval are
+// visible to the next. Every Interpreter has its own settings and imported
+// modules, but a single one must not be used by several goroutines at once.
+type Interpreter struct {
+	env *object.Environment
+}
+
+// BuiltinFunc is a Go function that can be called from Gorilla code.
+// Its arguments and result are converted with FromObject and ToObject.
+// A returned error is raised as a Gorilla runtime error, so it can be
+// caught with try/catch.
+type BuiltinFunc func(args ...any) (any, error)
+
+// ParseError reports the syntax errors that stopped a program from running.
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parser errors:\n\t" + strings.Join(e.Errors, "\n\t")
+}
+
+// RuntimeError is an uncaught error raised while evaluating a program.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string { return e.Err.Inspect() }
+
+// StackTrace returns the error followed by the calls that led to it.
+func (e *RuntimeError) StackTrace() string { return e.Err.StackTrace() }
+
+// processBuiltins act on the whole process, by ending it or reading its
+// standard input, which belongs to the host of an embedded interpreter.
+var processBuiltins = []string{"exit", "readint", "readline"}
+
+// NewInterpreter returns an Interpreter with an empty global environment,
+// which SetGlobal fills in. The builtins in processBuiltins raise an error
+// instead of running.
+func NewInterpreter(opts Options) *Interpreter {
+	session := object.NewSession()
+	session.SearchPath = opts.SearchPath
+	if opts.MaxDepth > 0 {
+		session.MaxDepth = opts.MaxDepth
+	}
+	session.Builtins = make(map[string]object.Object, len(processBuiltins))
+	for _, name := range processBuiltins {
+		session.Builtins[name] = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			return &object.Error{Message: fmt.Sprintf(
+				"`%s` is not available in an embedded interpreter", name)}
+		}}
+	}
+
+	return &Interpreter{env: object.NewSessionEnvironment(session)}
+}
+
+// Eval runs src and returns the value of its last statement.
+func (i *Interpreter) Eval(src string) (any, error) {
+	return i.eval(lexer.New(src))
+}
+
+// EvalFile runs the file at path. Imports in it are resolved relative to
+// its directory.
+func (i *Interpreter) EvalFile(path string) (any, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return i.eval(lexer.NewWithFile(string(src), path))
+}
+
+func (i *Interpreter) eval(l *lexer.Lexer) (any, error) {
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	return result(evaluator.Eval(program, i.env))
+}
+
+// SetGlobal binds name to value in the global environment.
+func (i *Interpreter) SetGlobal(name string, value any) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	i.env.Set(name, obj)
+	return nil
+}
+
+// Global returns the value bound to name in the global environment.
+func (i *Interpreter) Global(name string) (any, bool) {
+	obj, ok := i.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return FromObject(obj), true
+}
+
+// Call calls the global function fnName with args.
+func (i *Interpreter) Call(fnName string, args ...any) (any, error) {
+	fn, ok := i.env.Get(fnName)
+	if !ok {
+		return nil, fmt.Errorf("gorilla: %s is not defined", fnName)
+	}
+
+	if f, ok := fn.(*object.Function); ok && len(f.Parameters) != len(args) {
+		return nil, fmt.Errorf("gorilla: %s takes %d arguments, got %d",
+			fnName, len(f.Parameters), len(args))
+	}
+
+	objs := make([]object.Object, len(args))
+	for idx, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		objs[idx] = obj
+	}
+
+	return result(evaluator.ApplyFunction(fn, objs))
+}
+
+// RegisterBuiltin makes fn callable as name from code run by this
+// Interpreter. It shadows a standard builtin of the same name. Modules the
+// code imports have their own globals and don't see it.
+func (i *Interpreter) RegisterBuiltin(name string, fn BuiltinFunc) {
+	i.env.Set(name, wrapBuiltin(fn))
+}
+
+func wrapBuiltin(fn BuiltinFunc) *object.Builtin {
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		values := make([]any, len(args))
+		for idx, arg := range args {
+			values[idx] = FromObject(arg)
+		}
+
+		value, err := fn(values...)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+
+		obj, err := ToObject(value)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return obj
+	}}
+}
+
+func result(obj object.Object) (any, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+	if obj == nil {
+		return nil, nil
+	}
+	return FromObject(obj), nil
+}
+
+// ToObject converts a Go value to a Gorilla object. It accepts nil, bool,
+// every integer and float type, string, []any, map[string]any, BuiltinFunc
+// and object.Object, which is returned as is.
+func ToObject(value any) (object.Object, error) {
+	switch v := value.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case object.Object:
+		return v, nil
+	case bool:
+		if v {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case int:
+		return &object.Integer{Value: int64(v)}, nil
+	case int8:
+		return &object.Integer{Value: int64(v)}, nil
+	case int16:
+		return &object.Integer{Value: int64(v)}, nil
+	case int32:
+		return &object.Integer{Value: int64(v)}, nil
+	case int64:
+		return &object.Integer{Value: v}, nil
+	case uint8:
+		return &object.Integer{Value: int64(v)}, nil
+	case uint16:
+		return &object.Integer{Value: int64(v)}, nil
+	case uint32:
+		return &object.Integer{Value: int64(v)}, nil
+	case float32:
+		return &object.Float{Value: float64(v)}, nil
+	case float64:
+		return &object.Float{Value: v}, nil
+	case string:
+		return &object.String{Value: v}, nil
+	case []any:
+		elements := make([]object.Object, len(v))
+		for idx, elem := range v {
+			obj, err := ToObject(elem)
+			if err != nil {
+				return nil, err
+			}
+			elements[idx] = obj
+		}
+		return &object.Array{Elements: elements}, nil
+	case map[string]any:
+		return mapToHash(v)
+	case BuiltinFunc:
+		return wrapBuiltin(v), nil
+	case func(args ...any) (any, error):
+		return wrapBuiltin(v), nil
+	default:
+		return nil, fmt.Errorf("gorilla: cannot convert %T to a Gorilla value", value)
+	}
+}
+
+func mapToHash(m map[string]any) (object.Object, error) {
+	// Go maps are unordered, so the keys are added sorted to give the
+	// hash a predictable order.
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := object.NewHash()
+	for _, key := range keys {
+		value, err := ToObject(m[key])
+		if err != nil {
+			return nil, err
+		}
+		k := &object.String{Value: key}
+		hash.Set(k, value)
+	}
+	return hash, nil
+}
+
+// FromObject converts a Gorilla object to a Go value: integers to int64,
+// floats to float64, strings, booleans, null to nil, arrays to []any and
+// hashes to map[string]any, with non-string keys written as they print.
+// Any other object, such as a function, is returned unchanged, and so is
+// an array or hash inside itself, where converting it would never end.
+func FromObject(obj object.Object) any {
+	return fromObject(obj, map[object.Object]bool{})
+}
+
+// fromObject converts obj, which is inside the arrays and hashes in outer.
+func fromObject(obj object.Object, outer map[object.Object]bool) any {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return nil
+	case *object.Array:
+		if outer[obj] {
+			return obj
+		}
+		outer[obj] = true
+		defer delete(outer, obj)
+
+		values := make([]any, len(obj.Elements))
+		for idx, elem := range obj.Elements {
+			values[idx] = fromObject(elem, outer)
+		}
+		return values
+	case *object.Hash:
+		if outer[obj] {
+			return obj
+		}
+		outer[obj] = true
+		defer delete(outer, obj)
+
+		values := make(map[string]any, obj.Len())
+		for _, pair := range obj.Entries() {
+			values[pair.Key.Inspect()] = fromObject(pair.Value, outer)
+		}
+		return values
+	default:
+		return obj
+	}
+}
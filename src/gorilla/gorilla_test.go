@@ -0,0 +1,315 @@
+package gorilla
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorilla/object"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"1 + 2", int64(3)},
+		{"1.5 * 2", 3.0},
+		{`"go" + "rilla"`, "gorilla"},
+		{"1 < 2", true},
+		{"let x = 5;", nil},
+		{"[1, \"a\", [true]]", []any{int64(1), "a", []any{true}}},
+		{`{"a": 1, "b": first([])}`, map[string]any{"a": int64(1), "b": nil}},
+		{`{1: "one"}`, map[string]any{"1": "one"}},
+	}
+
+	for _, tt := range tests {
+		interp := NewInterpreter(Options{})
+		got, err := interp.Eval(tt.input)
+		if err != nil {
+			t.Fatalf("Eval(%q) returned error: %v", tt.input, err)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Eval(%q) = %#v, want %#v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalCycles(t *testing.T) {
+	interp := NewInterpreter(Options{})
+	got, err := interp.Eval("let a = [1]; a[0] = a; a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, ok := got.([]any)
+	if !ok || len(values) != 1 {
+		t.Fatalf("got %#v, want a one-element []any", got)
+	}
+	if inner, ok := values[0].(object.Object); !ok || inner.Inspect() != "[[...]]" {
+		t.Errorf("values[0] = %#v, want the array itself", values[0])
+	}
+
+	got, err = interp.Eval(`let h = {}; h["self"] = h; h["n"] = 1; h`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs, ok := got.(map[string]any)
+	if !ok || pairs["n"] != int64(1) {
+		t.Fatalf("got %#v, want a hash with n = 1", got)
+	}
+	if _, ok := pairs["self"].(object.Object); !ok {
+		t.Errorf("pairs[self] = %#v, want the hash itself", pairs["self"])
+	}
+}
+
+func TestEvalKeepsGlobals(t *testing.T) {
+	interp := NewInterpreter(Options{})
+	if _, err := interp.Eval("let total = 40;"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := interp.Eval("total + 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != int64(42) {
+		t.Errorf("got %#v, want 42", got)
+	}
+
+	value, ok := interp.Global("total")
+	if !ok || value != int64(40) {
+		t.Errorf("Global(total) = %#v, %t", value, ok)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	interp := NewInterpreter(Options{})
+
+	_, err := interp.Eval("let = 5;")
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected *ParseError, got %T (%v)", err, err)
+	}
+	if len(perr.Errors) == 0 {
+		t.Errorf("ParseError has no messages")
+	}
+
+	_, err = interp.Eval("let f = fn() { 1 + true }; f()")
+	var rerr *RuntimeError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected *RuntimeError, got %T (%v)", err, err)
+	}
+	if !strings.Contains(err.Error(), "type mismatch: INTEGER + BOOLEAN") {
+		t.Errorf("wrong error message: %q", err.Error())
+	}
+	if !strings.Contains(rerr.StackTrace(), "at f") {
+		t.Errorf("stack trace is missing the call to f:\n%s", rerr.StackTrace())
+	}
+}
+
+func TestEvalFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib.gor":  `let double = fn(x) { x * 2 };`,
+		"main.gor": "import \"lib\";\nlib.double(21)",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	interp := NewInterpreter(Options{})
+	got, err := interp.EvalFile(filepath.Join(dir, "main.gor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != int64(42) {
+		t.Errorf("got %#v, want 42", got)
+	}
+
+	if _, err := interp.EvalFile(filepath.Join(dir, "missing.gor")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestProcessBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	src := "let quit = fn() { exit(3) };"
+	if err := os.WriteFile(filepath.Join(dir, "lib.gor"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"exit(1)", "`exit` is not available in an embedded interpreter"},
+		{"readline()", "`readline` is not available in an embedded interpreter"},
+		{`readint("> ")`, "`readint` is not available in an embedded interpreter"},
+		{"import \"lib\"; lib.quit()", "`exit` is not available in an embedded interpreter"},
+	}
+
+	for _, tt := range tests {
+		interp := NewInterpreter(Options{SearchPath: []string{dir}})
+		_, err := interp.Eval(tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Eval(%q) error = %v, want %q", tt.input, err, tt.expected)
+		}
+	}
+
+	interp := NewInterpreter(Options{})
+	got, err := interp.Eval(`try { exit(1); "exited" } catch (e) { "caught" }`)
+	if err != nil || got != "caught" {
+		t.Errorf("got %#v, %v, want the error to be caught", got, err)
+	}
+}
+
+func TestInterpretersAreIndependent(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	for i, dir := range dirs {
+		src := fmt.Sprintf("let n = %d; let inc = fn() { n += 1; n };", i*10)
+		if err := os.WriteFile(filepath.Join(dir, "lib.gor"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each interpreter looks up imports in its own search path and has its
+	// own copy of the modules
+	first := NewInterpreter(Options{SearchPath: dirs[:1]})
+	second := NewInterpreter(Options{SearchPath: dirs[1:]})
+	for _, tt := range []struct {
+		interp   *Interpreter
+		expected int64
+	}{
+		{first, 1}, {second, 11}, {first, 2}, {second, 12},
+	} {
+		got, err := tt.interp.Eval(`import "lib"; lib.inc()`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.expected {
+			t.Errorf("got %#v, want %d", got, tt.expected)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	recurse := "let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(%d)"
+
+	shallow := NewInterpreter(Options{MaxDepth: 50})
+	if _, err := shallow.Eval(fmt.Sprintf(recurse, 100)); err == nil || !strings.Contains(err.Error(), "stack overflow") {
+		t.Errorf("expected a stack overflow with MaxDepth 50, got %v", err)
+	}
+
+	// The limit of one interpreter doesn't apply to another, which gets the
+	// default one
+	deep := NewInterpreter(Options{})
+	if _, err := deep.Eval(fmt.Sprintf(recurse, 100)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := deep.Eval("let g = fn() { g() }; g()"); err == nil || !strings.Contains(err.Error(), "stack overflow") {
+		t.Errorf("expected a stack overflow with the default limit, got %v", err)
+	}
+}
+
+func TestSetGlobal(t *testing.T) {
+	interp := NewInterpreter(Options{})
+
+	if err := interp.SetGlobal("base", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.SetGlobal("config", map[string]any{
+		"name":  "svc",
+		"ports": []any{80, 443},
+		"ratio": 0.5,
+		"debug": false,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := interp.Eval(`[base, config["name"], config["ports"][1], config["ratio"], config["debug"]]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []any{int64(10), "svc", int64(443), 0.5, false}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %#v, want %#v", got, expected)
+	}
+
+	if err := interp.SetGlobal("ch", make(chan int)); err == nil {
+		t.Errorf("expected an error converting a channel")
+	}
+}
+
+func TestCall(t *testing.T) {
+	interp := NewInterpreter(Options{})
+	_, err := interp.Eval(`
+let allow = fn(user, limit) {
+	if (user["admin"]) { return true; }
+	user["requests"] < limit
+};`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user     map[string]any
+		expected any
+	}{
+		{map[string]any{"admin": true, "requests": 100}, true},
+		{map[string]any{"admin": false, "requests": 3}, true},
+		{map[string]any{"admin": false, "requests": 30}, false},
+	}
+	for _, tt := range tests {
+		got, err := interp.Call("allow", tt.user, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.expected {
+			t.Errorf("allow(%v) = %#v, want %#v", tt.user, got, tt.expected)
+		}
+	}
+
+	if _, err := interp.Call("missing"); err == nil {
+		t.Errorf("expected an error calling an undefined function")
+	}
+	if _, err := interp.Call("allow", 1); err == nil {
+		t.Errorf("expected an error calling with too few arguments")
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	interp := NewInterpreter(Options{})
+	interp.RegisterBuiltin("lookup", func(args ...any) (any, error) {
+		key, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("lookup: key must be a string")
+		}
+		return map[string]any{"region": "eu"}[key], nil
+	})
+
+	got, err := interp.Eval(`lookup("region")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "eu" {
+		t.Errorf("got %#v, want %q", got, "eu")
+	}
+
+	got, err = interp.Eval(`try { lookup(1) } catch (e) { e["message"] }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "lookup: key must be a string" {
+		t.Errorf("got %#v", got)
+	}
+
+	_, err = interp.Eval(`lookup(1)`)
+	if err == nil || !strings.HasSuffix(err.Error(), "1:1: lookup: key must be a string") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ok.gor":     "let x = 1;",
+		"broken.gor": "let = 1;",
+		"fails.gor":  "let x = 1 + true;",
+		"tidy.gor":   "let x = 1;\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{path("ok.gor")}, exitOK},
+		{[]string{"run", path("ok.gor"), "a", "b"}, exitOK},
+		{[]string{"-engine", "vm", path("fails.gor")}, exitError},
+		{[]string{"run", path("fails.gor")}, exitError},
+		{[]string{"run", path("broken.gor")}, exitError},
+		{[]string{"run", path("missing.gor")}, exitError},
+		{[]string{"-e", "if (len(args) != 2) { throw 1 }", "a", "b"}, exitOK},
+		{[]string{"-engine", "vm", "-e", "if (len(args) != 2) { throw 1 }", "a"}, exitError},
+		{[]string{"check", path("ok.gor"), path("broken.gor")}, exitError},
+		{[]string{"check", path("ok.gor")}, exitOK},
+		{[]string{"-max-depth", "10", "-e", "let f = fn() { f() }; f()"}, exitError},
+		{[]string{"run"}, exitUsage},
+		{[]string{"repl", "extra"}, exitUsage},
+		{[]string{"-engine", "jit", "-e", "1"}, exitUsage},
+		{[]string{"tokens", path("ok.gor"), path("ok.gor")}, exitUsage},
+		{[]string{"-no-such-flag"}, exitUsage},
+		{[]string{"fmt", "-check", path("tidy.gor")}, exitOK},
+		{[]string{"fmt", "-check", path("tidy.gor"), path("ok.gor")}, exitError},
+		{[]string{"fmt", path("broken.gor")}, exitError},
+		{[]string{"fmt", "-w", "-check", path("ok.gor")}, exitUsage},
+		{[]string{"fmt", "-w", "-e", "1"}, exitUsage},
+	}
+
+	for _, tt := range tests {
+		if status := runMain(tt.args); status != tt.expected {
+			t.Errorf("wrong exit status for %q. want=%d, got=%d", tt.args, tt.expected, status)
+		}
+	}
+}
+
+func TestFmtWrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "messy.gor")
+	if err := os.WriteFile(file, []byte("let f=fn(x){\nx*2}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := runMain([]string{"fmt", "-w", file}); status != exitOK {
+		t.Fatalf("fmt -w failed with status %d", status)
+	}
+
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "let f = fn(x) {\n    x * 2;\n};\n"
+	if string(dat) != expected {
+		t.Errorf("file not rewritten. want=%q, got=%q", expected, string(dat))
+	}
+}
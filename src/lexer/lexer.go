@@ -2,50 +2,85 @@ package lexer
 
 import (
 	"gorilla/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Lexer reads the tokens of UTF-8 source code. Chars are runes, so a column
+// counts chars rather than bytes.
 type Lexer struct {
 	input        string
+	filename     string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	ch           rune // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
+
+	// templates holds, for each ${ of a string being read, the number of
+	// braces opened since, so the } that closes it can be told apart.
+	templates []int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFile(input, "")
+}
+
+// NewWithFile returns a lexer whose token positions carry the given file name.
+func NewWithFile(input, filename string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
+	l.column += 1
 }
 
-func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+}
 
+func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespace()
 
+	pos := l.currentPosition()
+	tok := l.nextToken()
+	tok.Pos = pos
+	tok.End = l.currentPosition()
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '#':
-		// Comment
-		for !(l.ch == '\n' || l.ch == 0) {
-			l.readChar()
-		}
-		tok.Type = token.STRING
-		tok.Literal = ""
+		tok.Type = token.COMMENT
+		tok.Literal = l.readLineComment()
+		return tok
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString('"')
+		if l.peekChar() == '"' && l.peekCharAt(2) == '"' {
+			tok = l.readRawString()
+		} else {
+			tok = l.readString('"', token.STRING, token.TEMPLATE_START)
+		}
 	case '\'':
-		tok.Type = token.STRING
-		tok.Literal = l.readString('\'')
+		tok = l.readString(l.ch, token.STRING, "")
 	case '=':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -55,9 +90,9 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.newAssignableToken(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.newAssignableToken(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -67,15 +102,22 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '*' {
+			return l.readBlockComment()
+		}
+		tok = l.newAssignableToken(token.SLASH, token.SLASH_ASSIGN)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		tok = l.newAssignableToken(token.ASTERISK, token.ASTERISK_ASSIGN)
 	case '%':
-		tok = newToken(token.MODULO, l.ch)
+		tok = l.newAssignableToken(token.MODULO, token.MODULO_ASSIGN)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		tok = l.newAssignableToken(token.LT, token.LT_EQ)
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		tok = l.newAssignableToken(token.GT, token.GT_EQ)
+	case '&':
+		tok = l.newDoubleToken(token.AND)
+	case '|':
+		tok = l.newDoubleToken(token.OR)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
@@ -85,15 +127,30 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '{':
+		if n := len(l.templates); n > 0 {
+			l.templates[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		n := len(l.templates)
+		switch {
+		case n > 0 && l.templates[n-1] == 0:
+			l.templates = l.templates[:n-1]
+			tok = l.readString('"', token.TEMPLATE_END, token.TEMPLATE_MIDDLE)
+		case n > 0:
+			l.templates[n-1]--
+			fallthrough
+		default:
+			tok = newToken(token.RBRACE, l.ch)
+		}
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -103,8 +160,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -114,10 +170,32 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
+// newAssignableToken returns a token of type op, or of type opAssign when the
+// operator is directly followed by '=' as in "+=" or "<=".
+func (l *Lexer) newAssignableToken(op, opAssign token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: opAssign, Literal: string(ch) + string(l.ch)}
+	}
+	return newToken(op, l.ch)
+}
+
+// newDoubleToken returns a token of type t for a doubled char like "&&". A
+// single char is ILLEGAL.
+func (l *Lexer) newDoubleToken(t token.TokenType) token.Token {
+	if l.peekChar() == l.ch {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: t, Literal: string(ch) + string(l.ch)}
+	}
+	return newToken(token.ILLEGAL, l.ch)
+}
+
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 	for isLetter(l.ch) {
@@ -126,73 +204,197 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
+// readLineComment reads a comment up to the end of the line, leaving the
+// newline.
+func (l *Lexer) readLineComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	return l.input[position:l.position]
+}
+
+// readBlockComment reads a /* */ comment. One left open is ILLEGAL.
+func (l *Lexer) readBlockComment() token.Token {
+	position := l.position
+	l.readChar()
+	for {
+		l.readChar()
+		if l.ch == 0 {
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[position:]}
+		}
+		if l.ch == '*' && l.peekChar() == '/' {
+			l.readChar()
+			l.readChar()
+			return token.Token{Type: token.COMMENT, Literal: l.input[position:l.position]}
+		}
+	}
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
 	}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or a float literal. A float has a fractional
+// part, an exponent, or both: 3.14, 1e-9, 2.5E+3.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	var tokenType token.TokenType = token.INT
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || (next == '+' || next == '-') && isDigit(rune(l.peekCharAt(2))) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return ch
 }
 
-func (l *Lexer) readString(endLiteral byte) string {
-	position := l.position + 1
+// peekCharAt returns the char n positions after the current one.
+func (l *Lexer) peekCharAt(n int) byte {
+	if l.position+n >= len(l.input) {
+		return 0
+	}
+	return l.input[l.position+n]
+}
+
+// readString reads a string up to the closing quote that isn't escaped,
+// returning a token of type end. A ${ in it ends the token early with type
+// interpolate, unless that is "", and the string goes on after the } that
+// closes the expression. A string left open is ILLEGAL.
+func (l *Lexer) readString(quote rune, end, interpolate token.TokenType) token.Token {
+	position := l.position
+	var out strings.Builder
 
 	for {
 		l.readChar()
-		if l.ch == endLiteral || l.ch == 0 {
-			break
+		switch {
+		case l.ch == quote:
+			return token.Token{Type: end, Literal: out.String()}
+		case l.ch == 0:
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[position:]}
+		case l.ch == '\\':
+			l.readEscape(&out)
+		case l.ch == '$' && l.peekChar() == '{' && interpolate != "":
+			l.readChar()
+			l.templates = append(l.templates, 0)
+			return token.Token{Type: interpolate, Literal: out.String()}
+		default:
+			out.WriteRune(l.ch)
 		}
 	}
+}
 
-	st := l.input[position:l.position]
-
-	// Escape sequence support: '\\', '\t', \n
-	length := len(st)
-	if length <= 1 {
-		return st
-	}
-	var s string
-	i := 0
-	for i < length-1 {
-		if st[i] == '\\' && st[i+1] == '\\' {
-			s += `\`
-			i += 1
-		} else if st[i] == '\\' && st[i+1] == 'n' {
-			s += "\n"
-			i += 1
-		} else if st[i] == '\\' && st[i+1] == 't' {
-			s += "\t"
-			i += 1
-		} else {
-			s += string(st[i])
+// readRawString reads a """ string, which has no escapes and may span
+// lines. A newline right after the opening quotes is dropped.
+func (l *Lexer) readRawString() token.Token {
+	position := l.position
+	l.readChar()
+	l.readChar()
+	start := l.readPosition
+	if l.peekChar() == '\n' {
+		start++
+	}
+
+	for {
+		l.readChar()
+		if l.ch == 0 {
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[position:]}
+		}
+		if l.ch == '"' && l.peekChar() == '"' && l.peekCharAt(2) == '"' {
+			literal := l.input[start:l.position]
+			l.readChar()
+			l.readChar()
+			return token.Token{Type: token.STRING, Literal: literal}
+		}
+	}
+}
+
+// escapes maps the char after a backslash to the char it stands for.
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+	'$':  '$',
+}
+
+// readEscape reads the escape sequence starting at the current backslash:
+// one of the escapes, \xHH or \u{H...} with up to six hex digits, both
+// naming a code point. Anything else keeps the backslash as it is.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	next := l.peekChar()
+	if ch, ok := escapes[next]; ok {
+		out.WriteRune(ch)
+		l.readChar()
+		return
+	}
+
+	rest := l.input[l.readPosition:]
+	var digits string
+	switch {
+	case next == 'x' && len(rest) >= 3:
+		digits = rest[1:3]
+	case next == 'u' && strings.HasPrefix(rest, "u{"):
+		if end := strings.IndexByte(rest, '}'); end > 2 && end <= 8 {
+			digits = rest[2:end]
 		}
-		i += 1
 	}
-	if i < length {
-		s += string(st[length-1])
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if digits == "" || err != nil || !utf8.ValidRune(rune(code)) {
+		out.WriteRune('\\')
+		return
 	}
+	out.WriteRune(rune(code))
 
-	return s
+	// The x or u{ and what follows, up to the last digit or the }
+	n := len(digits) + 1
+	if next == 'u' {
+		n += 2
+	}
+	for i := 0; i < n; i++ {
+		l.readChar()
+	}
 }
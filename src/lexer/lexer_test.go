@@ -15,7 +15,7 @@ let add = fn(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if ( 5 < 10 ) {
@@ -200,9 +200,9 @@ foo # comment
 		{token.STRING, "'"},
 
 		{token.IDENT, "foo"},
-		{token.STRING, ""},
+		{token.COMMENT, "# comment"},
 
-		{token.STRING, ""},
+		{token.COMMENT, "# comment"},
 
 		{token.EOF, ""},
 	}
@@ -223,3 +223,310 @@ foo # comment
 		}
 	}
 }
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`'it\'s'`, token.STRING, "it's"},
+		{`"a\r\n\t\0b"`, token.STRING, "a\r\n\t\x00b"},
+		{`"\\"`, token.STRING, `\`},
+		{`"\x41\xe9"`, token.STRING, "Aé"},
+		{`"\u{1F98D} \u{e9}"`, token.STRING, "🦍 é"},
+		{`"héllo wörld"`, token.STRING, "héllo wörld"},
+		{`"\d \x4 \xZZ \u{} \u{110000} \u{1234567}"`, token.STRING, `\d \x4 \xZZ \u{} \u{110000} \u{1234567}`},
+		{`"open`, token.ILLEGAL, `"open`},
+		{`"escaped end\"`, token.ILLEGAL, `"escaped end\"`},
+		{`"""raw \n "quoted" """`, token.STRING, `raw \n "quoted" `},
+		{"\"\"\"\nfirst\nsecond\"\"\"", token.STRING, "first\nsecond"},
+		{`"""open ""`, token.ILLEGAL, `"""open ""`},
+	}
+
+	for i, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - token wrong. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	input := `"a ${x} b ${ {"k": f("${y}")}["k"] } c\${d}" 'e${f}' """
+g "${h}" \n""" } "i ${j`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE_START, "a "},
+		{token.IDENT, "x"},
+		{token.TEMPLATE_MIDDLE, " b "},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.TEMPLATE_START, ""},
+		{token.IDENT, "y"},
+		{token.TEMPLATE_END, ""},
+		{token.RPAREN, ")"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_END, " c${d}"},
+		{token.STRING, "e${f}"},
+		{token.STRING, `g "${h}" \n`},
+		{token.RBRACE, "}"},
+		{token.TEMPLATE_START, "i "},
+		{token.IDENT, "j"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := `let größe = "日本";
+größe + ünïcode`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "größe", 1, 5},
+		{token.ASSIGN, "=", 1, 11},
+		{token.STRING, "日本", 1, 13},
+		{token.SEMICOLON, ";", 1, 17},
+		{token.IDENT, "größe", 2, 1},
+		{token.PLUS, "+", 2, 7},
+		{token.IDENT, "ünïcode", 2, 9},
+		{token.EOF, "", 2, 16},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `## doc
+x /* a
+b */ / y # rest
+/*/ */ /* open`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.COMMENT, "## doc", 1, 1},
+		{token.IDENT, "x", 2, 1},
+		{token.COMMENT, "/* a\nb */", 2, 3},
+		{token.SLASH, "/", 3, 6},
+		{token.IDENT, "y", 3, 8},
+		{token.COMMENT, "# rest", 3, 10},
+		{token.COMMENT, "/*/ */", 4, 1},
+		{token.ILLEGAL, "/* open", 4, 8},
+		{token.EOF, "", 4, 15},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + "ab"`
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+		expectedEnd    int
+	}{
+		{token.LET, 1, 1, 4},
+		{token.IDENT, 1, 5, 6},
+		{token.ASSIGN, 1, 7, 8},
+		{token.INT, 1, 9, 10},
+		{token.SEMICOLON, 1, 10, 11},
+		{token.IDENT, 2, 3, 4},
+		{token.PLUS, 2, 5, 6},
+		{token.STRING, 2, 7, 11},
+		{token.EOF, 2, 11, 12},
+	}
+
+	l := NewWithFile(input, "test.gor")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos.Filename != "test.gor" {
+			t.Fatalf("tests[%d] - filename wrong. got=%q", i, tok.Pos.Filename)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+		if tok.End.Line != tt.expectedLine || tok.End.Column != tt.expectedEnd {
+			t.Fatalf("tests[%d] - end position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedEnd, tok.End.Line, tok.End.Column)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := `5 3.14 1e-9 2.5E+3 10e2 7.x 4e`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.FLOAT, "10e2"},
+		{token.INT, "7"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.INT, "4"},
+		{token.IDENT, "e"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	input := `x = 1; x += 2; x -= 3; x *= 4; x /= 5; x %= 6; x == y`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"}, {token.ASSIGN, "="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.PLUS_ASSIGN, "+="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.MINUS_ASSIGN, "-="}, {token.INT, "3"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.ASTERISK_ASSIGN, "*="}, {token.INT, "4"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.SLASH_ASSIGN, "/="}, {token.INT, "5"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.MODULO_ASSIGN, "%="}, {token.INT, "6"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.EQ, "=="}, {token.IDENT, "y"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLogicalAndComparisonOperators(t *testing.T) {
+	input := `a && b || c <= d >= e & f | g`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "d"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "e"},
+		{token.ILLEGAL, "&"},
+		{token.IDENT, "f"},
+		{token.ILLEGAL, "|"},
+		{token.IDENT, "g"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
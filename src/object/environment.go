@@ -1,13 +1,28 @@
 package object
 
+import "sort"
+
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	outer   *Environment
+	session *Session
 }
 
+// NewEnvironment returns a global environment with a session of its own.
 func NewEnvironment() *Environment {
+	return NewSessionEnvironment(NewSession())
+}
+
+// NewSessionEnvironment returns a global environment that belongs to
+// session, like the one of a module imported in that session.
+func NewSessionEnvironment(session *Session) *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, session: session}
+}
+
+// Session returns the session the environment belongs to.
+func (e *Environment) Session() *Session {
+	return e.session
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -23,8 +38,38 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Assign updates an existing binding in the scope that declared it and
+// reports whether such a binding was found.
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
+// Names returns every name visible from this environment, sorted.
+func (e *Environment) Names() []string {
+	seen := map[string]bool{}
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
+	env := NewSessionEnvironment(outer.session)
 	env.outer = outer
 	return env
 }
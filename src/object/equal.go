@@ -0,0 +1,115 @@
+package object
+
+import "math"
+
+// Equal reports whether a and b are equal the way == compares them.
+// Numbers are equal when their values are, an integer to a float too.
+// Strings, booleans, null and ranges are equal by value. Arrays are equal
+// when their elements are equal in order, and hashes when they have the
+// same keys with equal values, in any order. Any other object, such as a
+// function, is only equal to itself.
+func Equal(a, b Object) bool {
+	return equal(a, b, nil)
+}
+
+// visit is a pair of arrays or hashes being compared. Meeting it again
+// means a and b contain themselves, and the pair is taken to be equal so
+// that the comparison ends.
+type visit struct {
+	a, b Object
+}
+
+func equal(a, b Object, seen map[visit]bool) bool {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return float64(a.Value) == b.Value
+		}
+		return false
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == float64(b.Value)
+		case *Float:
+			return a.Value == b.Value
+		}
+		return false
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Range:
+		b, ok := b.(*Range)
+		return ok && *a == *b
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if a == b || seen[visit{a, b}] {
+			return true
+		}
+		seen = markSeen(seen, a, b)
+		for i, el := range a.Elements {
+			if !equal(el, b.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		if a == b || seen[visit{a, b}] {
+			return true
+		}
+		seen = markSeen(seen, a, b)
+		for _, pair := range a.pairs {
+			if pair == nil {
+				continue
+			}
+			value, ok := b.Get(pair.Key)
+			if !ok || !equal(pair.Value, value, seen) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func markSeen(seen map[visit]bool, a, b Object) map[visit]bool {
+	if seen == nil {
+		seen = make(map[visit]bool)
+	}
+	seen[visit{a, b}] = true
+	return seen
+}
+
+// Same reports whether a and b are the same value. Arrays, hashes and
+// other objects that can change or hold state are only the same as
+// themselves. Integers, floats, strings, booleans, null and ranges never
+// change, so they are the same when their type and value are: a float is
+// the same as itself even when it is nan, and never the same as an integer.
+func Same(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *Float:
+		b, ok := b.(*Float)
+		return ok && math.Float64bits(a.Value) == math.Float64bits(b.Value)
+	case *String, *Boolean, *Null, *Range:
+		return Equal(a, b)
+	}
+	return a == b
+}
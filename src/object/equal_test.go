@@ -0,0 +1,81 @@
+package object
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	hash := func(pairs ...Object) *Hash {
+		h := NewHash()
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i].(Hashable), pairs[i+1])
+		}
+		return h
+	}
+	one, two := &Integer{Value: 1}, &Integer{Value: 2}
+	a, b := &String{Value: "a"}, &String{Value: "b"}
+	nan := &Float{Value: math.NaN()}
+
+	cyclic1 := &Array{Elements: []Object{one, nil}}
+	cyclic1.Elements[1] = cyclic1
+	cyclic2 := &Array{Elements: []Object{one, nil}}
+	cyclic2.Elements[1] = cyclic2
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{one, &Integer{Value: 1}, true},
+		{one, &Float{Value: 1}, true},
+		{&Float{Value: 2}, two, true},
+		{one, two, false},
+		{nan, nan, false},
+		{a, &String{Value: "a"}, true},
+		{a, one, false},
+		{&Null{}, &Null{}, true},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Range{0, 3, 1}, &Range{0, 3, 1}, true},
+		{&Array{Elements: []Object{one, a}}, &Array{Elements: []Object{one, a}}, true},
+		{&Array{Elements: []Object{one, a}}, &Array{Elements: []Object{a, one}}, false},
+		{hash(a, one, b, two), hash(b, two, a, one), true},
+		{hash(a, one), hash(a, two), false},
+		{hash(a, one), hash(b, one), false},
+		{hash(a, one), hash(a, one, b, two), false},
+		{cyclic1, cyclic2, true},
+		{&Function{}, &Function{}, false},
+	}
+
+	for i, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] Equal(%s, %s) wrong. want=%t, got=%t",
+				i, tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestSame(t *testing.T) {
+	arr := &Array{}
+	nan := math.NaN()
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{arr, arr, true},
+		{arr, &Array{}, false},
+		{NewHash(), NewHash(), false},
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Float{Value: 1}, false},
+		{&Float{Value: nan}, &Float{Value: nan}, true},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&Null{}, &Null{}, true},
+	}
+
+	for i, tt := range tests {
+		if got := Same(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] Same(%s, %s) wrong. want=%t, got=%t",
+				i, tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package object
+
+// Freeze returns obj frozen, and false if it contains itself, which a
+// frozen value can't. Arrays and hashes are copied, with the arrays and
+// hashes inside them frozen too, unless they are frozen already. Any other
+// value can't be changed, and is returned as it is.
+func Freeze(obj Object) (Object, bool) {
+	return freeze(obj, map[Object]bool{})
+}
+
+// freeze freezes obj, which is inside the arrays and hashes in outer.
+func freeze(obj Object, outer map[Object]bool) (Object, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		if obj.Frozen {
+			return obj, true
+		}
+		if outer[obj] {
+			return nil, false
+		}
+		outer[obj] = true
+		defer delete(outer, obj)
+
+		elements := make([]Object, len(obj.Elements))
+		for i, el := range obj.Elements {
+			frozen, ok := freeze(el, outer)
+			if !ok {
+				return nil, false
+			}
+			elements[i] = frozen
+		}
+		return &Array{Elements: elements, Frozen: true}, true
+
+	case *Hash:
+		if obj.Frozen {
+			return obj, true
+		}
+		if outer[obj] {
+			return nil, false
+		}
+		outer[obj] = true
+		defer delete(outer, obj)
+
+		// Keys are frozen already, or they couldn't be keys
+		hash := NewHash()
+		for _, pair := range obj.pairs {
+			if pair == nil {
+				continue
+			}
+			frozen, ok := freeze(pair.Value, outer)
+			if !ok {
+				return nil, false
+			}
+			hash.Set(pair.Key, frozen)
+		}
+		hash.Frozen = true
+		return hash, true
+	}
+	return obj, true
+}
@@ -0,0 +1,39 @@
+package object
+
+import "testing"
+
+func TestFreeze(t *testing.T) {
+	inner := &Array{Elements: []Object{&Integer{Value: 1}}}
+	hash := NewHash()
+	hash.Set(&String{Value: "inner"}, inner)
+	arr := &Array{Elements: []Object{inner, hash, inner}}
+
+	obj, ok := Freeze(arr)
+	if !ok {
+		t.Fatalf("Freeze refused a value that doesn't contain itself")
+	}
+	frozen := obj.(*Array)
+	if !frozen.Frozen || frozen == arr || arr.Frozen {
+		t.Fatalf("Freeze didn't return a frozen copy")
+	}
+	if el := frozen.Elements[0].(*Array); !el.Frozen || el == inner || inner.Frozen {
+		t.Errorf("Freeze didn't freeze a copy of the array inside")
+	}
+	value, _ := frozen.Elements[1].(*Hash).Get(&String{Value: "inner"})
+	if !frozen.Elements[1].(*Hash).Frozen || !value.(*Array).Frozen {
+		t.Errorf("Freeze didn't freeze the hash inside and its values")
+	}
+	if again, _ := Freeze(frozen); again != frozen {
+		t.Errorf("Freeze copied a frozen array")
+	}
+	integer := &Integer{Value: 1}
+	if again, _ := Freeze(integer); again != integer {
+		t.Errorf("Freeze copied an integer")
+	}
+
+	cyclic := &Array{Elements: []Object{nil}}
+	cyclic.Elements[0] = &Array{Elements: []Object{cyclic}}
+	if _, ok := Freeze(cyclic); ok {
+		t.Errorf("Freeze accepted an array that contains itself")
+	}
+}
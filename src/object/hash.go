@@ -0,0 +1,270 @@
+package object
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+)
+
+// Hashable is a value that can be a hash key. Arrays and hashes have a
+// HashKey but are only keys once frozen: use AsHashable to get a key.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+// AsHashable returns obj as a hash key, and false if it can't be one.
+// Arrays and hashes can be keys when they are frozen, so that a key never
+// changes while it is in a hash.
+func AsHashable(obj Object) (Hashable, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj, obj.Frozen
+	case *Hash:
+		return obj, obj.Frozen
+	}
+	hashable, ok := obj.(Hashable)
+	return hashable, ok
+}
+
+// HashKey is the hash of a key. Different keys can have the same HashKey,
+// so a hash compares the keys themselves as well.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// hashString hashes the value of a string key. Tests replace it to force
+// collisions.
+var hashString = func(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	} else {
+		value = 0
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// -0 has the HashKey of 0, which == finds equal to it. A float never has
+// the HashKey of an integer though: unlike ==, keys tell 1 from 1.0.
+func (f *Float) HashKey() HashKey {
+	value := f.Value
+	if value == 0 {
+		value = 0
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(value)}
+}
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Value: hashString(s.Value)}
+}
+
+// The HashKey of an array comes from the HashKeys of its elements in
+// order, and that of a hash from the HashKeys of its pairs in any order.
+// An element or value that can't be a key only adds its type.
+func (ao *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	for _, el := range ao.Elements {
+		writeHashKey(h, elementHashKey(el))
+	}
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
+func (h *Hash) HashKey() HashKey {
+	var sum uint64
+	for _, pair := range h.pairs {
+		if pair == nil {
+			continue
+		}
+		ph := fnv.New64a()
+		writeHashKey(ph, pair.Key.HashKey())
+		writeHashKey(ph, elementHashKey(pair.Value))
+		sum += ph.Sum64()
+	}
+	return HashKey{Type: h.Type(), Value: sum}
+}
+
+func elementHashKey(obj Object) HashKey {
+	if hashable, ok := AsHashable(obj); ok {
+		return hashable.HashKey()
+	}
+	return HashKey{Type: obj.Type()}
+}
+
+func writeHashKey(h hash.Hash64, key HashKey) {
+	h.Write([]byte(key.Type))
+	h.Write(binary.LittleEndian.AppendUint64(nil, key.Value))
+}
+
+// sameKey reports whether a and b, which have the same HashKey, are the
+// same key: Same values, 0 and -0, or arrays or hashes made of the same
+// keys. Unlike ==, it tells an integer from an equal float, as their
+// HashKeys do.
+func sameKey(a, b Object) bool {
+	switch a := a.(type) {
+	case *Float:
+		b, ok := b.(*Float)
+		return ok && (a.Value == b.Value || Same(a, b))
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i, el := range a.Elements {
+			if !sameKey(el, b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, pair := range a.pairs {
+			if pair == nil {
+				continue
+			}
+			value, ok := b.Get(pair.Key)
+			if !ok || !sameKey(pair.Value, value) {
+				return false
+			}
+		}
+		return true
+	}
+	return Same(a, b)
+}
+
+type HashPair struct {
+	Key   Hashable
+	Value Object
+
+	index int // position in the pairs of the hash
+}
+
+// Hash maps keys to values in the order the keys were added, which is the
+// order a hash is printed and iterated in. A key is looked up by its
+// HashKey among the few pairs in its bucket, which are compared key by key.
+//
+// Deleting a pair leaves a hole in the order, so that it doesn't move the
+// pairs after it. The holes are removed once they are half of the order.
+type Hash struct {
+	buckets map[HashKey][]*HashPair
+	pairs   []*HashPair // in insertion order, nil where a pair was deleted
+	deleted int         // number of nils in pairs
+	Frozen  bool        // can't be changed, and so can be a hash key
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]*HashPair)}
+}
+
+func (h *Hash) find(key Hashable) *HashPair {
+	for _, pair := range h.buckets[key.HashKey()] {
+		if sameKey(pair.Key, key) {
+			return pair
+		}
+	}
+	return nil
+}
+
+// Get returns the value of key, and whether the hash has it.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	if pair := h.find(key); pair != nil {
+		return pair.Value, true
+	}
+	return nil, false
+}
+
+// Set adds a pair, or replaces the value of an existing key, which keeps
+// its place in the order.
+func (h *Hash) Set(key Hashable, value Object) {
+	if pair := h.find(key); pair != nil {
+		pair.Value = value
+		return
+	}
+
+	pair := &HashPair{Key: key, Value: value, index: len(h.pairs)}
+	hk := key.HashKey()
+	h.buckets[hk] = append(h.buckets[hk], pair)
+	h.pairs = append(h.pairs, pair)
+}
+
+// Delete removes key and reports whether the hash had it.
+func (h *Hash) Delete(key Hashable) bool {
+	hk := key.HashKey()
+	bucket := h.buckets[hk]
+	for i, pair := range bucket {
+		if !sameKey(pair.Key, key) {
+			continue
+		}
+
+		if len(bucket) == 1 {
+			delete(h.buckets, hk)
+		} else {
+			h.buckets[hk] = append(bucket[:i:i], bucket[i+1:]...)
+		}
+
+		h.pairs[pair.index] = nil
+		h.deleted++
+		if h.deleted > len(h.pairs)/2 {
+			h.compact()
+		}
+		return true
+	}
+	return false
+}
+
+// compact removes the holes deleted pairs left in the order.
+func (h *Hash) compact() {
+	pairs := make([]*HashPair, 0, h.Len())
+	for _, pair := range h.pairs {
+		if pair != nil {
+			pair.index = len(pairs)
+			pairs = append(pairs, pair)
+		}
+	}
+	h.pairs = pairs
+	h.deleted = 0
+}
+
+// Len returns the number of pairs.
+func (h *Hash) Len() int {
+	return len(h.pairs) - h.deleted
+}
+
+// Entries returns the pairs in insertion order. Later changes to the hash
+// don't show in them.
+func (h *Hash) Entries() []HashPair {
+	pairs := make([]HashPair, 0, h.Len())
+	for _, pair := range h.pairs {
+		if pair != nil {
+			pairs = append(pairs, *pair)
+		}
+	}
+	return pairs
+}
+
+// Copy returns a new hash with the same pairs in the same order. The copy
+// isn't frozen.
+func (h *Hash) Copy() *Hash {
+	c := NewHash()
+	for _, pair := range h.pairs {
+		if pair != nil {
+			c.Set(pair.Key, pair.Value)
+		}
+	}
+	return c
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string  { return inspect(h, map[Object]bool{}) }
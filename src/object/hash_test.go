@@ -0,0 +1,216 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// collide makes every string hash the same until the test ends.
+func collide(t *testing.T) {
+	saved := hashString
+	hashString = func(string) uint64 { return 0 }
+	t.Cleanup(func() { hashString = saved })
+}
+
+func TestHashCollisionsKeepAllKeys(t *testing.T) {
+	collide(t)
+
+	a, b := &String{Value: "a"}, &String{Value: "b"}
+	if a.HashKey() != b.HashKey() {
+		t.Fatalf("hashString wasn't replaced")
+	}
+
+	hash := NewHash()
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+
+	if hash.Len() != 2 {
+		t.Fatalf("wrong Len. want=2, got=%d", hash.Len())
+	}
+	for _, tt := range []struct {
+		key      Hashable
+		expected string
+	}{
+		{&String{Value: "a"}, "1"},
+		{&String{Value: "b"}, "2"},
+	} {
+		value, ok := hash.Get(tt.key)
+		if !ok {
+			t.Errorf("no pair for %s", tt.key.Inspect())
+			continue
+		}
+		if value.Inspect() != tt.expected {
+			t.Errorf("wrong value for %s. want=%s, got=%s", tt.key.Inspect(), tt.expected, value.Inspect())
+		}
+	}
+	if _, ok := hash.Get(&String{Value: "c"}); ok {
+		t.Errorf("Get found a pair for a key that was never set")
+	}
+}
+
+func TestHashCollisionsSet(t *testing.T) {
+	collide(t)
+
+	hash := NewHash()
+	for _, key := range []string{"a", "b", "c"} {
+		hash.Set(&String{Value: key}, &String{Value: key})
+	}
+	hash.Set(&String{Value: "b"}, &Integer{Value: 2})
+
+	if got := hash.Inspect(); got != "{a: a, b: 2, c: c}" {
+		t.Errorf("Set of a colliding key wrong. got=%s", got)
+	}
+}
+
+func TestHashCollisionsDelete(t *testing.T) {
+	collide(t)
+
+	hash := NewHash()
+	for _, key := range []string{"a", "b", "c"} {
+		hash.Set(&String{Value: key}, &String{Value: key})
+	}
+
+	if hash.Delete(&String{Value: "d"}) {
+		t.Errorf("Delete of a missing colliding key reported a pair")
+	}
+	if !hash.Delete(&String{Value: "b"}) {
+		t.Fatalf("Delete(b) reported no pair")
+	}
+	if got := hash.Inspect(); got != "{a: a, c: c}" {
+		t.Errorf("wrong hash after Delete. got=%s", got)
+	}
+	if _, ok := hash.Get(&String{Value: "b"}); ok {
+		t.Errorf("Get found b after Delete")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := hash.Get(&String{Value: key}); !ok {
+			t.Errorf("Delete(b) removed %s", key)
+		}
+	}
+
+	hash.Set(&String{Value: "b"}, &String{Value: "again"})
+	if got := hash.Inspect(); got != "{a: a, c: c, b: again}" {
+		t.Errorf("wrong hash after Set of a deleted key. got=%s", got)
+	}
+}
+
+func TestHashCollisionsCopy(t *testing.T) {
+	collide(t)
+
+	hash := NewHash()
+	for i := 0; i < 10; i++ {
+		hash.Set(&String{Value: fmt.Sprint(i)}, &Integer{Value: int64(i)})
+	}
+	copied := hash.Copy()
+	copied.Delete(&String{Value: "3"})
+	copied.Set(&String{Value: "4"}, &Integer{Value: 40})
+
+	if got := hash.Inspect(); got != "{0: 0, 1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9}" {
+		t.Errorf("changing the copy changed the hash. got=%s", got)
+	}
+	if got := copied.Inspect(); got != "{0: 0, 1: 1, 2: 2, 4: 40, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9}" {
+		t.Errorf("wrong copy. got=%s", got)
+	}
+}
+
+func TestHashKeysOfDifferentTypes(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&Integer{Value: 1}, &String{Value: "integer"})
+	hash.Set(&String{Value: "1"}, &String{Value: "string"})
+	hash.Set(&Boolean{Value: true}, &String{Value: "boolean"})
+	hash.Set(&Float{Value: 1}, &String{Value: "float"})
+
+	if hash.Len() != 4 {
+		t.Fatalf("keys of different types share a pair. got=%s", hash.Inspect())
+	}
+	if value, _ := hash.Get(&Integer{Value: 1}); value.Inspect() != "integer" {
+		t.Errorf("wrong value for 1. got=%s", value.Inspect())
+	}
+}
+
+func TestZeroFloatKeys(t *testing.T) {
+	zero, negZero := &Float{Value: 0}, &Float{Value: math.Copysign(0, -1)}
+	frozenArray := func(elements ...Object) *Array {
+		return &Array{Elements: elements, Frozen: true}
+	}
+	if zero.HashKey() != negZero.HashKey() {
+		t.Fatalf("0.0 and -0.0 have different HashKeys")
+	}
+
+	hash := NewHash()
+	hash.Set(zero, &String{Value: "zero"})
+	hash.Set(negZero, &String{Value: "negative zero"})
+	hash.Set(frozenArray(negZero), &String{Value: "array"})
+
+	if hash.Len() != 2 {
+		t.Fatalf("0.0 and -0.0 are different keys. got=%s", hash.Inspect())
+	}
+	if value, _ := hash.Get(zero); value.Inspect() != "negative zero" {
+		t.Errorf("wrong value for 0.0. got=%s", value.Inspect())
+	}
+	if _, ok := hash.Get(frozenArray(zero)); !ok {
+		t.Errorf("[0.0] not found as [-0.0]")
+	}
+	if _, ok := hash.Get(&Integer{Value: 0}); ok {
+		t.Errorf("0 found as 0.0")
+	}
+}
+
+func TestCompositeKeyCollisions(t *testing.T) {
+	collide(t)
+
+	frozen := func(elements ...Object) *Array {
+		return &Array{Elements: elements, Frozen: true}
+	}
+	a, b := &String{Value: "a"}, &String{Value: "b"}
+	if frozen(a).HashKey() != frozen(b).HashKey() {
+		t.Fatalf("hashString wasn't replaced")
+	}
+
+	hash := NewHash()
+	hash.Set(frozen(a, b), &Integer{Value: 1})
+	hash.Set(frozen(b, a), &Integer{Value: 2})
+	hash.Set(frozen(a), &Integer{Value: 3})
+	hash.Set(frozen(frozen(a)), &Integer{Value: 4})
+
+	if hash.Len() != 4 {
+		t.Fatalf("colliding keys share a pair. got=%s", hash.Inspect())
+	}
+	if value, _ := hash.Get(frozen(&String{Value: "b"}, &String{Value: "a"})); value.Inspect() != "2" {
+		t.Errorf("wrong value for [b, a]. got=%s", value.Inspect())
+	}
+	if !hash.Delete(frozen(a)) || hash.Inspect() != "{[a, b]: 1, [b, a]: 2, [[a]]: 4}" {
+		t.Errorf("wrong hash after Delete([a]). got=%s", hash.Inspect())
+	}
+}
+
+func TestCompositeHashKeys(t *testing.T) {
+	one, two := &Integer{Value: 1}, &Integer{Value: 2}
+	a, b := &String{Value: "a"}, &String{Value: "b"}
+
+	ab, ba := NewHash(), NewHash()
+	ab.Set(a, one)
+	ab.Set(b, two)
+	ba.Set(b, two)
+	ba.Set(a, one)
+	if ab.HashKey() != ba.HashKey() {
+		t.Errorf("hashes with the same pairs in another order have different hash keys")
+	}
+
+	arr12 := &Array{Elements: []Object{one, two}}
+	arr21 := &Array{Elements: []Object{two, one}}
+	if arr12.HashKey() == arr21.HashKey() {
+		t.Errorf("arrays with elements in another order have the same hash key")
+	}
+
+	for _, obj := range []Object{arr12, ab} {
+		if _, ok := AsHashable(obj); ok {
+			t.Errorf("AsHashable accepted a %s that isn't frozen", obj.Type())
+		}
+		frozen, _ := Freeze(obj)
+		if _, ok := AsHashable(frozen); !ok {
+			t.Errorf("AsHashable refused a frozen %s", obj.Type())
+		}
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"gorilla/ast"
-	"hash/fnv"
+	"gorilla/code"
+	"gorilla/token"
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -13,6 +16,7 @@ type ObjectType string
 const (
 	NULL_OBJ         = "NULL"
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	STRING_OBJ       = "STRING"
 	ARRAY_OBJ        = "ARRAY"
@@ -21,6 +25,12 @@ const (
 	ERROR_OBJ        = "ERROR"
 	HASH_OBJ         = "HASH"
 	BUILTIN_OBJ      = "BUILTIN"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	RANGE_OBJ        = "RANGE"
+	MODULE_OBJ       = "MODULE"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 )
 
 type Object interface {
@@ -28,9 +38,6 @@ type Object interface {
 	Inspect() string
 }
 
-type Hashable interface {
-	HashKey() HashKey
-}
 type Integer struct {
 	Value int64
 }
@@ -38,6 +45,36 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect always shows a float as a float: 2.0 rather than 2, and switches
+// to exponent notation for very large and very small magnitudes.
+func (f *Float) Inspect() string {
+	switch {
+	case math.IsNaN(f.Value):
+		return "nan"
+	case math.IsInf(f.Value, 1):
+		return "inf"
+	case math.IsInf(f.Value, -1):
+		return "-inf"
+	}
+
+	abs := math.Abs(f.Value)
+	if abs != 0 && (abs < 1e-4 || abs >= 1e16) {
+		return strconv.FormatFloat(f.Value, 'e', -1, 64)
+	}
+
+	s := strconv.FormatFloat(f.Value, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -64,103 +101,238 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break and Continue are signals that travel up from a break or continue
+// statement to the nearest enclosing loop. They never become user values.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
 	Message string
+	Pos     token.Position // where the error was raised, if known
+	Stack   []StackFrame   // calls the error passed through, innermost first
+	Value   Object         // the value given to throw, nil for interpreter errors
+}
+
+// StackFrame records one function call an error propagated out of.
+type StackFrame struct {
+	Function string
+	Pos      token.Position // position of the call expression
+	Args     []Object
+}
+
+func (sf StackFrame) String() string {
+	args := []string{}
+	for _, a := range sf.Args {
+		args = append(args, a.Inspect())
+	}
+	return fmt.Sprintf("at %s(%s) (%s)", sf.Function, strings.Join(args, ", "), sf.Pos)
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
+
+// StackTrace returns the error followed by one line per stack frame.
+func (e *Error) StackTrace() string {
+	var out bytes.Buffer
+	out.WriteString(e.Inspect())
+	for _, frame := range e.Stack {
+		out.WriteString("\n    ")
+		out.WriteString(frame.String())
+	}
+	return out.String()
+}
 
 type Function struct {
+	Name       string
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
-func (f *Function) Inspect() string {
+func (f *Function) Inspect() string  { return inspectFunction(f.Parameters, f.Body) }
+
+func inspectFunction(parameters []*ast.Identifier, body *ast.BlockStatement) string {
 	var out bytes.Buffer
 	params := []string{}
-	for _, p := range f.Parameters {
+	for _, p := range parameters {
 		params = append(params, p.String())
 	}
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("\n}")
 	return out.String()
 }
 
+// CompiledFunction is a function literal compiled to bytecode. The VM wraps
+// it in a closure before it can be called.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	Name          string
+	LocalNames    []string // names of the local slots, for error messages
+	FreeNames     []string // names of the captured variables
+	SourceMap     *code.SourceMap
+	CallNames     map[int]string // callee identifier of each call instruction
+	Literal       *ast.FunctionLiteral
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	if cf.Literal == nil {
+		return fmt.Sprintf("CompiledFunction[%p]", cf)
+	}
+	return inspectFunction(cf.Literal.Parameters, cf.Literal.Body)
+}
+
 type BuiltinFunction func(args ...Object) Object
+
+// Caller calls a function or a builtin the way the engine running the
+// program does, for builtins that take functions as arguments.
+type Caller func(fn Object, args ...Object) Object
+
+// CallbackFunction is a builtin that calls back the functions it is given,
+// like map, with call.
+type CallbackFunction func(call Caller, args ...Object) Object
+
+// Builtin is a function implemented in Go. It has either Fn or CallbackFn.
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn         BuiltinFunction
+	CallbackFn CallbackFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
+// Call calls the builtin with args, using call for any callbacks.
+func (b *Builtin) Call(call Caller, args ...Object) Object {
+	if b.CallbackFn != nil {
+		return b.CallbackFn(call, args...)
+	}
+	return b.Fn(args...)
+}
+
 type Array struct {
 	Elements []Object
+	Frozen   bool // can't be changed, and so can be a hash key
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
-func (ao *Array) Inspect() string {
-	var out bytes.Buffer
-	elements := []string{}
-	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
-	}
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
-	return out.String()
-}
+func (ao *Array) Inspect() string  { return inspect(ao, map[Object]bool{}) }
 
-type HashKey struct {
-	Type  ObjectType
-	Value uint64
-}
+// inspect shows obj, which is inside the arrays and hashes in outer. An
+// array or hash that contains itself shows as [...] or {...} where it
+// comes back, so that printing it ends.
+func inspect(obj Object, outer map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if outer[obj] {
+			return "[...]"
+		}
+		outer[obj] = true
+		defer delete(outer, obj)
+
+		var out bytes.Buffer
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, outer))
+		}
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+		return out.String()
 
-func (b *Boolean) HashKey() HashKey {
-	var value uint64
-	if b.Value {
-		value = 1
-	} else {
-		value = 0
+	case *Hash:
+		if outer[obj] {
+			return "{...}"
+		}
+		outer[obj] = true
+		defer delete(outer, obj)
+
+		var out bytes.Buffer
+		pairs := []string{}
+		for _, pair := range obj.pairs {
+			if pair == nil {
+				continue
+			}
+			pairs = append(pairs, fmt.Sprintf("%s: %s",
+				inspect(pair.Key, outer), inspect(pair.Value, outer)))
+		}
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+		return out.String()
 	}
-	return HashKey{Type: b.Type(), Value: value}
+	return obj.Inspect()
 }
-func (i *Integer) HashKey() HashKey {
-	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+
+// Range is the lazy sequence start, start+step, ... up to but excluding stop.
+type Range struct {
+	Start int64
+	Stop  int64
+	Step  int64
 }
-func (s *String) HashKey() HashKey {
-	h := fnv.New64a()
-	h.Write([]byte(s.Value))
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
 }
 
-type HashPair struct {
-	Key   Object
-	Value Object
+// Len returns the number of values in the range, or math.MaxInt64 if there
+// are more, which only happens with a step of 1 or -1 across most of the
+// int64 values.
+func (r *Range) Len() int64 {
+	n := r.count()
+	if n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
 }
 
-// Hashmap data structure
-type Hash struct {
-	Pairs map[HashKey]HashPair
+// Fits reports whether Len is the number of values in the range.
+func (r *Range) Fits() bool {
+	return r.count() <= math.MaxInt64
 }
 
-func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+// count returns the number of values in the range. The distance between
+// start and stop and the size of the step always fit in a uint64, even
+// when they don't in an int64.
+func (r *Range) count() uint64 {
+	var span, step uint64
+	switch {
+	case r.Step > 0 && r.Start < r.Stop:
+		span, step = uint64(r.Stop)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && r.Start > r.Stop:
+		span, step = uint64(r.Start)-uint64(r.Stop), -uint64(r.Step)
+	default:
+		return 0
 	}
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
-	return out.String()
+	return (span-1)/step + 1
 }
+
+// Module is the namespace an imported file is bound to. Its exported members
+// are the top-level bindings of the file whose names don't start with "_".
+type Module struct {
+	Name string
+	Path string
+	Env  *Environment
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return fmt.Sprintf("<module %s>", m.Name) }
@@ -1,6 +1,12 @@
 package object
 
-import "testing"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -20,3 +26,133 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{2, "2.0"},
+		{3.14, "3.14"},
+		{-0.5, "-0.5"},
+		{0, "0.0"},
+		{12345678, "12345678.0"},
+		{1e-9, "1e-09"},
+		{1e20, "1e+20"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %g. want=%q, got=%q", tt.value, tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestRangeLen(t *testing.T) {
+	tests := []struct {
+		r        Range
+		expected int64
+		fits     bool
+	}{
+		{Range{0, 10, 3}, 4, true},
+		{Range{10, 0, -1}, 10, true},
+		{Range{0, 0, 1}, 0, true},
+		{Range{5, 0, 1}, 0, true},
+		{Range{-math.MaxInt64, math.MaxInt64, 2}, math.MaxInt64, true},
+		{Range{math.MaxInt64, math.MinInt64, math.MinInt64}, 2, true},
+		{Range{math.MinInt64, math.MaxInt64, math.MaxInt64}, 3, true},
+		{Range{math.MinInt64, math.MaxInt64, 1}, math.MaxInt64, false},
+		{Range{math.MaxInt64, math.MinInt64, -1}, math.MaxInt64, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Len(); got != tt.expected {
+			t.Errorf("wrong Len for %s. want=%d, got=%d", tt.r.Inspect(), tt.expected, got)
+		}
+		if got := tt.r.Fits(); got != tt.fits {
+			t.Errorf("wrong Fits for %s. want=%t, got=%t", tt.r.Inspect(), tt.fits, got)
+		}
+	}
+}
+
+func TestHashDeleteAndCopy(t *testing.T) {
+	a, b, c := &String{Value: "a"}, &String{Value: "b"}, &String{Value: "c"}
+	hash := NewHash()
+	for i, key := range []*String{a, b, c} {
+		hash.Set(key, &Integer{Value: int64(i)})
+	}
+
+	entries := hash.Entries()
+	copied := hash.Copy()
+
+	if !hash.Delete(b) {
+		t.Fatalf("Delete(b) reported no pair")
+	}
+	if hash.Delete(b) {
+		t.Errorf("Delete(b) again reported a pair")
+	}
+	if hash.Len() != 2 || hash.Inspect() != "{a: 0, c: 2}" {
+		t.Errorf("wrong hash after Delete. got=%s", hash.Inspect())
+	}
+	if len(entries) != 3 || entries[1].Key != b {
+		t.Errorf("Delete changed the entries taken before it: %v", entries)
+	}
+	if copied.Len() != 3 || copied.Inspect() != "{a: 0, b: 1, c: 2}" {
+		t.Errorf("Delete changed the copy. got=%s", copied.Inspect())
+	}
+
+	copied.Set(a, &Integer{Value: 10})
+	if value, _ := hash.Get(a); value.Inspect() != "0" {
+		t.Errorf("Set on the copy changed the hash. got=%s", value.Inspect())
+	}
+}
+
+func TestHashDeleteMany(t *testing.T) {
+	hash := NewHash()
+	for i := 0; i < 100; i++ {
+		hash.Set(&Integer{Value: int64(i)}, &Integer{Value: int64(i)})
+	}
+
+	// Deleting every key but the multiples of 10 leaves them in order,
+	// with the holes compacted away
+	for i := 0; i < 100; i++ {
+		if i%10 != 0 && !hash.Delete(&Integer{Value: int64(i)}) {
+			t.Fatalf("Delete(%d) reported no pair", i)
+		}
+	}
+	if hash.Len() != 10 || len(hash.pairs) > 2*hash.Len() {
+		t.Errorf("holes not compacted. got %d pairs in %d slots", hash.Len(), len(hash.pairs))
+	}
+	hash.Set(&Integer{Value: 5}, &Integer{Value: 5})
+	hash.Delete(&Integer{Value: 0})
+	if got := hash.Inspect(); got != "{10: 10, 20: 20, 30: 30, 40: 40, 50: 50, 60: 60, 70: 70, 80: 80, 90: 90, 5: 5}" {
+		t.Errorf("wrong hash after deleting. got=%s", got)
+	}
+
+	for i, pair := range hash.Entries() {
+		if value, ok := hash.Get(pair.Key); !ok || value != pair.Value {
+			t.Errorf("entry %d (%s) not found", i, pair.Key.Inspect())
+		}
+	}
+	if !hash.Delete(&Integer{Value: 5}) || hash.Len() != 9 {
+		t.Errorf("wrong hash after deleting the last key. got=%s", hash.Inspect())
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	hash := NewHash()
+	var expected []string
+	for i := 40; i > 0; i-- {
+		key := &Integer{Value: int64(i)}
+		hash.Set(key, &String{Value: strconv.Itoa(i * i)})
+		expected = append(expected, fmt.Sprintf("%d: %d", i, i*i))
+	}
+
+	want := "{" + strings.Join(expected, ", ") + "}"
+	for i := 0; i < 5; i++ {
+		if got := hash.Inspect(); got != want {
+			t.Fatalf("hash.Inspect() wrong.\nwant=%q\ngot =%q", want, got)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package object
+
+// DefaultMaxDepth is how deeply function calls can nest in a new session.
+const DefaultMaxDepth = 10000
+
+// Session is the state of one interpreter that all the code it runs
+// shares, the modules that code imports included. Interpreters with
+// different sessions don't see each other's modules or settings.
+type Session struct {
+	// SearchPath lists the directories an import is looked up in after
+	// the directory of the importing file.
+	SearchPath []string
+
+	// MaxDepth limits how deeply function calls can nest before running
+	// fails with a stack overflow. Zero means no limit, which lets deep
+	// recursion crash the whole process.
+	MaxDepth int
+
+	// Depth is the number of function calls the evaluator is in.
+	Depth int
+
+	// Modules caches every imported file by absolute path, so each module
+	// is run only once per session.
+	Modules map[string]Object
+
+	// Loading holds the modules currently being run, innermost last. A
+	// path that is already on it means an import cycle.
+	Loading []string
+
+	// Builtins replace the standard builtins of the same name in all the
+	// code the evaluator runs in the session, imported modules included.
+	Builtins map[string]Object
+}
+
+func NewSession() *Session {
+	return &Session{MaxDepth: DefaultMaxDepth, Modules: make(map[string]Object)}
+}
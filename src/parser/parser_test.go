@@ -139,6 +139,37 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "2.5;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != 2.5 {
+		t.Errorf("literal.Value not %g. got=%g", 2.5, literal.Value)
+	}
+	if literal.TokenLiteral() != "2.5" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "2.5",
+			literal.TokenLiteral())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -198,6 +229,10 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 % 5;", 5, "%", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"true && false;", true, "&&", false},
+		{"true || false;", true, "||", false},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
 		{"foobar + barfoo;", "foobar", "+", "barfoo"},
@@ -350,6 +385,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c != d",
+			"((a == b) && (c != d))",
+		},
+		{
+			"!a && b",
+			"((!a) && b)",
+		},
+		{
+			"x = a || b",
+			"x = (a || b)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -843,6 +902,63 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestTemplateLiteral(t *testing.T) {
+	input := `"Hello ${name}, ${a + b}${"!"}"`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	template, ok := stmt.Expression.(*ast.TemplateLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.TemplateLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(template.Parts) != 7 {
+		t.Fatalf("template.Parts has wrong length. want=7, got=%d", len(template.Parts))
+	}
+	for i, text := range []string{"Hello ", ", ", "", ""} {
+		part, ok := template.Parts[2*i].(*ast.StringLiteral)
+		if !ok || part.Value != text {
+			t.Errorf("template.Parts[%d] is not the text %q. got=%s", 2*i, text, template.Parts[2*i])
+		}
+	}
+	testIdentifier(t, template.Parts[1], "name")
+	testInfixExpression(t, template.Parts[3], "a", "+", "b")
+	if str, ok := template.Parts[5].(*ast.StringLiteral); !ok || str.Value != "!" {
+		t.Errorf("template.Parts[5] is not the string %q. got=%s", "!", template.Parts[5])
+	}
+
+	expected := `"Hello ${name}, ${(a + b)}${!}"`
+	if template.String() != expected {
+		t.Errorf("template.String() wrong. want=%q, got=%q", expected, template.String())
+	}
+}
+
+func TestTemplateLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`"a ${} b"; x`, []string{"1:6: empty ${} in string"}},
+		{`"a ${x y} b"; x`, []string{"1:8: expected } to close ${, got IDENT instead"}},
+		{`"a ${"${1 1}"} ${} b"; x`, []string{"1:11: expected } to close ${, got INT instead"}},
+		{`"a ${x`, []string{"1:1: string not terminated"}},
+		{`"a ${x} b`, []string{"1:1: string not terminated"}},
+		{`"""open`, []string{"1:1: string not terminated"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if fmt.Sprint(p.Errors()) != fmt.Sprint(tt.expected) {
+			t.Errorf("wrong errors for %q. want=%q, got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 	l := lexer.New(input)
@@ -1100,3 +1216,539 @@ func TestWhileExpression(t *testing.T) {
 		return
 	}
 }
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 5;", "1:5: expected next token to be IDENT, got = instead"},
+		{"let x = 5;\nlet y 6;", "2:7: expected next token to be =, got INT instead"},
+		{"let x = 5;\n  ;", "2:3: no prefix parse function for ; found"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(a, b) {
+	a + b
+};
+add(1, 2)[0];`
+
+	l := lexer.NewWithFile(input, "pos.gor")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Value.(*ast.FunctionLiteral)
+	body := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	index := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{program, "pos.gor:1:1"},
+		{let, "pos.gor:1:1"},
+		{let.Name, "pos.gor:1:5"},
+		{fn, "pos.gor:1:11"},
+		{fn.Parameters[1], "pos.gor:1:17"},
+		{body.Expression, "pos.gor:2:2"},
+		{index, "pos.gor:4:1"},
+		{index.Left.(*ast.CallExpression).Arguments[1], "pos.gor:4:8"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.node.Pos().String(); got != tt.expected {
+			t.Errorf("tests[%d] - wrong position for %q. expected=%q, got=%q",
+				i, tt.node.String(), tt.expected, got)
+		}
+	}
+}
+
+func TestNodeEnds(t *testing.T) {
+	input := `let add = fn(a, b) {
+	a + b
+};
+add(1, 2)[0];
+let h = {"k": [1, 2]}
+"x${h}y"
+if (true) { 1 } else {
+  2 }
+try { 1 } catch (e) { 2 }
+import "lib" as l;
+-h.k`
+
+	l := lexer.NewWithFile(input, "end.gor")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Value.(*ast.FunctionLiteral)
+	body := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	index := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	hash := program.Statements[2].(*ast.LetStatement).Value.(*ast.HashLiteral)
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{program, "end.gor:11:5"},
+		{let, "end.gor:3:2"},
+		{fn.Parameters[1], "end.gor:1:18"},
+		{body.Expression, "end.gor:2:7"},
+		{index, "end.gor:4:13"},
+		{index.Left, "end.gor:4:10"},
+		{hash, "end.gor:5:22"},
+		{hash.Pairs[hash.Keys[0]], "end.gor:5:21"},
+		{program.Statements[3], "end.gor:6:9"},
+		{program.Statements[4], "end.gor:8:6"},
+		{program.Statements[5], "end.gor:9:26"},
+		{program.Statements[6], "end.gor:10:18"},
+		{program.Statements[7], "end.gor:11:5"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.node.End().String(); got != tt.expected {
+			t.Errorf("tests[%d] - wrong end for %q. expected=%q, got=%q",
+				i, tt.node.String(), tt.expected, got)
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input         string
+		catchParam    string
+		hasCatch      bool
+		hasFinally    bool
+		expectedBlock string
+	}{
+		{`try { x } catch (e) { y }`, "e", true, false, "x"},
+		{`try { x } catch { y }`, "", true, false, "x"},
+		{`try { x } finally { z }`, "", false, true, "x"},
+		{`try { x } catch (err) { y } finally { z }`, "err", true, true, "x"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T",
+				stmt.Expression)
+		}
+
+		if exp.Block.String() != tt.expectedBlock {
+			t.Errorf("try block wrong. want=%q, got=%q", tt.expectedBlock, exp.Block.String())
+		}
+		if (exp.Catch != nil) != tt.hasCatch {
+			t.Errorf("catch clause presence wrong. want=%t", tt.hasCatch)
+		}
+		if (exp.Finally != nil) != tt.hasFinally {
+			t.Errorf("finally clause presence wrong. want=%t", tt.hasFinally)
+		}
+		if tt.catchParam == "" {
+			if exp.CatchParam != nil {
+				t.Errorf("catch param should be nil. got=%q", exp.CatchParam.Value)
+			}
+		} else if !testIdentifier(t, exp.CatchParam, tt.catchParam) {
+			return
+		}
+	}
+}
+
+func TestTryWithoutHandlers(t *testing.T) {
+	l := lexer.New("try { x }")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	expected := "1:1: expected catch or finally after try block"
+	if len(errors) != 1 || errors[0] != expected {
+		t.Fatalf("wrong parser errors. want=[%q], got=%q", expected, errors)
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	l := lexer.New(`throw "oops";`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+	if stmt.TokenLiteral() != "throw" {
+		t.Fatalf("stmt.TokenLiteral not 'throw', got %q", stmt.TokenLiteral())
+	}
+
+	literal, ok := stmt.Value.(*ast.StringLiteral)
+	if !ok || literal.Value != "oops" {
+		t.Fatalf("stmt.Value is not the string \"oops\". got=%T (%+v)", stmt.Value, stmt.Value)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5;", "x = 5"},
+		{"x += 1 * 2;", "x += (1 * 2)"},
+		{"x -= y;", "x -= y"},
+		{"a = b = c;", "a = b = c"},
+		{"arr[0] = 1;", "(arr[0]) = 1"},
+		{`h["k"] %= 3;`, `(h[k]) %= 3`},
+		{"x = y == z;", "x = (y == z)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T",
+				stmt.Expression)
+		}
+
+		if exp.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, exp.String())
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2;", "1:3: cannot assign to 1"},
+		{"f() += 2;", "1:5: cannot assign to f()"},
+		{"a == b = c;", "1:8: cannot assign to (a == b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestLoopControlStatements(t *testing.T) {
+	input := `while (true) { if (x) { break; } continue }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	while := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.WhileExpression)
+	if len(while.Body.Statements) != 2 {
+		t.Fatalf("while body does not contain 2 statements. got=%d",
+			len(while.Body.Statements))
+	}
+
+	ifExp := while.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if _, ok := ifExp.Consequence.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("statement is not *ast.BreakStatement. got=%T", ifExp.Consequence.Statements[0])
+	}
+	if _, ok := while.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("statement is not *ast.ContinueStatement. got=%T", while.Body.Statements[1])
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside loop"},
+		{"if (true) { continue }", "1:13: continue outside loop"},
+		{"while (true) { fn() { break } }", "1:23: break outside loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("expected 1 parser error for %q, got %q", tt.input, errors)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedKey      string
+		expectedValue    string
+		expectedIterable string
+	}{
+		{"for (x in xs) { x }", "", "x", "xs"},
+		{"for (k, v in h) { k }", "k", "v", "h"},
+		{"for (i in range(0, 10)) { i }", "", "i", "range(0, 10)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.ForExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.ForExpression. got=%T",
+				stmt.Expression)
+		}
+
+		if tt.expectedKey == "" {
+			if exp.Key != nil {
+				t.Errorf("exp.Key should be nil. got=%q", exp.Key.Value)
+			}
+		} else if !testIdentifier(t, exp.Key, tt.expectedKey) {
+			return
+		}
+		if !testIdentifier(t, exp.Value, tt.expectedValue) {
+			return
+		}
+		if exp.Iterable.String() != tt.expectedIterable {
+			t.Errorf("exp.Iterable wrong. want=%q, got=%q",
+				tt.expectedIterable, exp.Iterable.String())
+		}
+		if len(exp.Body.Statements) != 1 {
+			t.Errorf("body does not contain 1 statements. got=%d",
+				len(exp.Body.Statements))
+		}
+	}
+}
+
+func TestForLoopControl(t *testing.T) {
+	l := lexer.New("for (x in xs) { if (x) { break } continue }")
+	p := New(l)
+	p.ParseProgram()
+	checkParserErrors(t, p)
+}
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedPath  string
+		expectedAlias string
+	}{
+		{`import "lib/util.gor";`, "lib/util.gor", ""},
+		{`import "util" as u`, "util", "u"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ImportStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Path.Value != tt.expectedPath {
+			t.Errorf("stmt.Path wrong. want=%q, got=%q", tt.expectedPath, stmt.Path.Value)
+		}
+		if tt.expectedAlias == "" {
+			if stmt.Alias != nil {
+				t.Errorf("stmt.Alias should be nil. got=%q", stmt.Alias.Value)
+			}
+		} else if !testIdentifier(t, stmt.Alias, tt.expectedAlias) {
+			return
+		}
+	}
+}
+
+func TestMemberExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"m.x", "(m.x)"},
+		{"m.f(1, 2)", "(m.f)(1, 2)"},
+		{"a.b.c", "((a.b).c)"},
+		{"m.xs[0]", "((m.xs)[0])"},
+		{"-m.x * 2", "((-(m.x)) * 2)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `# leading
+let x = [1, # one
+  2 /* two */, 3] # trailing
+x /* a */ + /* b */ 1
+`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "let x = [1, 2, 3];(x + 1)"
+	if program.String() != expected {
+		t.Errorf("comments changed the program. want=%q, got=%q", expected, program.String())
+	}
+
+	comments := p.Comments()
+	literals := []string{"# leading", "# one", "/* two */", "# trailing", "/* a */", "/* b */"}
+	if len(comments) != len(literals) {
+		t.Fatalf("wrong number of comments. want=%d, got=%d", len(literals), len(comments))
+	}
+	for i, lit := range literals {
+		if comments[i].Literal != lit {
+			t.Errorf("comments[%d] wrong. want=%q, got=%q", i, lit, comments[i].Literal)
+		}
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	p := New(lexer.New("let x = 1; /* open"))
+	p.ParseProgram()
+
+	expected := []string{"1:12: comment not terminated"}
+	if fmt.Sprint(p.Errors()) != fmt.Sprint(expected) {
+		t.Errorf("wrong errors. want=%q, got=%q", expected, p.Errors())
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	p := New(lexer.New(`let s = "say \"hi\";`))
+	p.ParseProgram()
+
+	expected := []string{"1:9: string not terminated"}
+	if fmt.Sprint(p.Errors()) != fmt.Sprint(expected) {
+		t.Errorf("wrong errors. want=%q, got=%q", expected, p.Errors())
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `## Adds two numbers.
+##   Both must be integers.
+let add = fn(a, b) { a + b };
+
+## Not attached, the let is further down.
+
+let a = 1;
+## Not attached to an expression.
+a;
+## Not attached, another comment follows.
+# plain
+let b = 2;
+let c = 3; ## Trailing, not attached.
+let d = 4;
+`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := map[string]string{
+		"add": "Adds two numbers.\nBoth must be integers.",
+		"a":   "",
+		"b":   "",
+		"c":   "",
+		"d":   "",
+	}
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+		if let.Doc != expected[let.Name.Value] {
+			t.Errorf("wrong doc for %s. want=%q, got=%q", let.Name.Value, expected[let.Name.Value], let.Doc)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package repl
+
+import (
+	"fmt"
+	"gorilla/ast"
+	"gorilla/lexer"
+	"gorilla/object"
+	"gorilla/token"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// command is a meta-command of the REPL, typed as :name followed by its
+// argument.
+type command struct {
+	name string
+	args string
+	help string
+	run  func(out io.Writer, engine Engine, arg string)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"tokens", "<code>", "show the tokens the lexer reads from code", tokensCommand},
+		{"ast", "<code>", "show the syntax tree the parser builds from code", astCommand},
+		{"env", "", "list the bindings of the session", envCommand},
+		{"type", "<expr>", "show the type of the value of expr", typeCommand},
+		{"load", "<file>", "run a file in the session", loadCommand},
+		{"reset", "", "forget every binding and imported module of the session", resetCommand},
+		{"time", "<code>", "run code and show how long it took", timeCommand},
+		{"help", "", "list the meta-commands", helpCommand},
+	}
+}
+
+// runCommand runs the meta-command input starts with.
+func runCommand(out io.Writer, engine Engine, input string) {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(input, ":"), " ")
+	name = strings.TrimSpace(name)
+	arg = strings.TrimSpace(arg)
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if cmd.args != "" && arg == "" {
+			fmt.Fprintf(out, "usage: :%s %s\n", cmd.name, cmd.args)
+			return
+		}
+		cmd.run(out, engine, arg)
+		return
+	}
+
+	fmt.Fprintf(out, "unknown command :%s, type :help for a list\n", name)
+}
+
+func tokensCommand(out io.Writer, engine Engine, arg string) {
+	l := lexer.New(arg)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(out, "%-6s %-10s %q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+}
+
+func astCommand(out io.Writer, engine Engine, arg string) {
+	if program, ok := parse(out, arg, ""); ok {
+		io.WriteString(out, ast.Dump(program))
+	}
+}
+
+func envCommand(out io.Writer, engine Engine, arg string) {
+	for _, name := range engine.Names() {
+		val, ok := engine.Get(name)
+		if !ok {
+			continue
+		}
+		inspected := strings.Join(strings.Fields(val.Inspect()), " ")
+		fmt.Fprintf(out, "%s: %s = %s\n", name, val.Type(), inspected)
+	}
+}
+
+func typeCommand(out io.Writer, engine Engine, arg string) {
+	program, ok := parse(out, arg, "")
+	if !ok {
+		return
+	}
+
+	evaluated := engine.Run(program)
+	switch evaluated := evaluated.(type) {
+	case *object.Error:
+		printResult(out, evaluated)
+	case nil:
+		fmt.Fprintln(out, "no value")
+	default:
+		fmt.Fprintln(out, evaluated.Type())
+	}
+}
+
+func loadCommand(out io.Writer, engine Engine, arg string) {
+	dat, err := os.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return
+	}
+
+	if program, ok := parse(out, string(dat), arg); ok {
+		if err, ok := engine.Run(program).(*object.Error); ok {
+			printResult(out, err)
+		}
+	}
+}
+
+func resetCommand(out io.Writer, engine Engine, arg string) {
+	engine.Reset()
+}
+
+func timeCommand(out io.Writer, engine Engine, arg string) {
+	program, ok := parse(out, arg, "")
+	if !ok {
+		return
+	}
+
+	start := time.Now()
+	evaluated := engine.Run(program)
+	elapsed := time.Since(start)
+
+	printResult(out, evaluated)
+	fmt.Fprintf(out, "time: %s\n", elapsed)
+}
+
+func helpCommand(out io.Writer, engine Engine, arg string) {
+	for _, cmd := range commands {
+		usage := ":" + cmd.name
+		if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		fmt.Fprintf(out, "  %-15s %s\n", usage, cmd.help)
+	}
+}
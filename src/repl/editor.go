@@ -0,0 +1,321 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errInterrupt is returned by readLine when Ctrl-C is pressed.
+var errInterrupt = errors.New("interrupt")
+
+// Control keys
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// editor reads lines from a terminal in raw mode, with cursor movement,
+// history and tab completion.
+type editor struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	history *History
+
+	// complete returns the names that can complete the given prefix
+	complete func(prefix string) []string
+
+	buf []rune
+	pos int // cursor position in buf
+
+	prompt string
+
+	histIndex int    // line of the history shown, Len() for the new line
+	draft     string // the new line, while the history is shown
+}
+
+func newEditor(in io.Reader, out io.Writer, history *History, complete func(string) []string) *editor {
+	return &editor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		history:  history,
+		complete: complete,
+	}
+}
+
+// readLine shows prompt and returns the line typed after it. It returns
+// io.EOF when Ctrl-D is pressed on an empty line and errInterrupt when
+// Ctrl-C is pressed.
+func (e *editor) readLine(prompt string) (string, error) {
+	e.prompt = prompt
+	e.buf = e.buf[:0]
+	e.pos = 0
+	e.histIndex = e.history.Len()
+	e.draft = ""
+	e.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyCR, keyLF:
+			io.WriteString(e.out, "\r\n")
+			return string(e.buf), nil
+
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupt
+
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteForward()
+
+		case keyBackspace, keyCtrlH:
+			e.deleteBackward()
+
+		case keyCtrlA:
+			e.moveTo(0)
+		case keyCtrlE:
+			e.moveTo(len(e.buf))
+		case keyCtrlB:
+			e.moveTo(e.pos - 1)
+		case keyCtrlF:
+			e.moveTo(e.pos + 1)
+		case keyCtrlP:
+			e.showHistory(e.histIndex - 1)
+		case keyCtrlN:
+			e.showHistory(e.histIndex + 1)
+
+		case keyCtrlK:
+			e.buf = e.buf[:e.pos]
+			e.refresh()
+
+		case keyCtrlU:
+			e.buf = append(e.buf[:0], e.buf[e.pos:]...)
+			e.pos = 0
+			e.refresh()
+
+		case keyCtrlW:
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+			e.pos = start
+			e.refresh()
+
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+			e.refresh()
+
+		case keyTab:
+			e.completeWord()
+
+		case keyEscape:
+			if err := e.readEscape(); err != nil {
+				return "", err
+			}
+
+		default:
+			if r >= ' ' && r != utf8.RuneError {
+				e.insert(r)
+			}
+		}
+	}
+}
+
+// readEscape handles the escape sequences sent by arrow keys and the like.
+func (e *editor) readEscape() error {
+	b, err := e.in.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b != '[' && b != 'O' {
+		return nil
+	}
+
+	// A CSI sequence is parameter bytes followed by a final letter or ~
+	var params []byte
+	for {
+		b, err = e.in.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+		params = append(params, b)
+	}
+
+	switch {
+	case b == 'A':
+		e.showHistory(e.histIndex - 1)
+	case b == 'B':
+		e.showHistory(e.histIndex + 1)
+	case b == 'C':
+		e.moveTo(e.pos + 1)
+	case b == 'D':
+		e.moveTo(e.pos - 1)
+	case b == 'H':
+		e.moveTo(0)
+	case b == 'F':
+		e.moveTo(len(e.buf))
+	case b == '~' && (string(params) == "1" || string(params) == "7"):
+		e.moveTo(0)
+	case b == '~' && (string(params) == "4" || string(params) == "8"):
+		e.moveTo(len(e.buf))
+	case b == '~' && string(params) == "3":
+		e.deleteForward()
+	}
+	return nil
+}
+
+func (e *editor) insert(r rune) {
+	e.buf = append(e.buf, 0)
+	copy(e.buf[e.pos+1:], e.buf[e.pos:])
+	e.buf[e.pos] = r
+	e.pos++
+	e.refresh()
+}
+
+func (e *editor) deleteBackward() {
+	if e.pos == 0 {
+		return
+	}
+	e.buf = append(e.buf[:e.pos-1], e.buf[e.pos:]...)
+	e.pos--
+	e.refresh()
+}
+
+func (e *editor) deleteForward() {
+	if e.pos == len(e.buf) {
+		return
+	}
+	e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+	e.refresh()
+}
+
+func (e *editor) moveTo(pos int) {
+	if pos < 0 || pos > len(e.buf) {
+		return
+	}
+	e.pos = pos
+	e.refresh()
+}
+
+// showHistory replaces the line by the i-th line of the history. Going
+// past the newest line brings back what was being typed.
+func (e *editor) showHistory(i int) {
+	if i < 0 || i > e.history.Len() || i == e.histIndex {
+		return
+	}
+	if e.histIndex == e.history.Len() {
+		e.draft = string(e.buf)
+	}
+
+	e.histIndex = i
+	if i == e.history.Len() {
+		e.buf = []rune(e.draft)
+	} else {
+		e.buf = []rune(e.history.Get(i))
+	}
+	e.pos = len(e.buf)
+	e.refresh()
+}
+
+// completeWord completes the name before the cursor. A single match is
+// inserted whole; several matches are completed to their common prefix,
+// or listed when that adds nothing.
+func (e *editor) completeWord() {
+	start := e.pos
+	for start > 0 && isNameRune(e.buf[start-1]) {
+		start--
+	}
+	prefix := string(e.buf[start:e.pos])
+
+	var matches []string
+	for _, name := range e.complete(prefix) {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	if len(matches) == 0 {
+		return
+	}
+	sort.Strings(matches)
+
+	completion := matches[0]
+	for _, m := range matches[1:] {
+		completion = commonPrefix(completion, m)
+	}
+
+	if len(completion) > len(prefix) {
+		for _, r := range completion[len(prefix):] {
+			e.buf = append(e.buf, 0)
+			copy(e.buf[e.pos+1:], e.buf[e.pos:])
+			e.buf[e.pos] = r
+			e.pos++
+		}
+		e.refresh()
+		return
+	}
+
+	if len(matches) > 1 {
+		io.WriteString(e.out, "\r\n"+strings.Join(matches, "  ")+"\r\n")
+		e.refresh()
+	}
+}
+
+// refresh redraws the prompt and the line and puts the cursor in place.
+// The newlines of an input of several lines brought back from the history
+// show as ↵, so that it fits on the line.
+func (e *editor) refresh() {
+	line := strings.ReplaceAll(string(e.buf), "\n", "↵")
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, line)
+	if back := len(e.buf) - e.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
@@ -0,0 +1,156 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HistoryFile is the name of the history file in the home directory.
+const HistoryFile = ".gorilla_history"
+
+// MaxHistory is the number of entries the history keeps.
+const MaxHistory = 1000
+
+// History holds the inputs typed into the REPL, oldest first, and keeps
+// them in a file so that they survive the session. An input of several
+// lines is a single entry, kept on one line of the file with its newlines
+// escaped.
+type History struct {
+	entries []string
+	path    string
+	saved   int // entries in the file, which can be more than in memory
+}
+
+// DefaultHistoryPath returns the path of the history file in the home
+// directory, or "" when there is no home directory.
+func DefaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HistoryFile)
+}
+
+// LoadHistory reads the history kept in path. A missing or unreadable file
+// gives an empty history, and an empty path one that is not saved at all.
+func LoadHistory(path string) *History {
+	h := &History{path: path}
+	if path == "" {
+		return h
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, unescapeHistory(line))
+		}
+	}
+	f.Close()
+
+	h.saved = len(h.entries)
+	if len(h.entries) > MaxHistory {
+		h.entries = h.entries[len(h.entries)-MaxHistory:]
+	}
+	return h
+}
+
+// Len returns the number of entries in the history.
+func (h *History) Len() int { return len(h.entries) }
+
+// Get returns the i-th entry, the oldest being 0.
+func (h *History) Get(i int) string { return h.entries[i] }
+
+// Add appends input to the history and its file. Blank inputs and repeats
+// of the last entry are left out. The file grows by a line per entry until
+// it holds twice MaxHistory of them, and is then rewritten with the
+// entries in memory. If the file can't be written, Add returns the error
+// and stops saving the history.
+func (h *History) Add(input string) error {
+	if strings.TrimSpace(input) == "" {
+		return nil
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == input {
+		return nil
+	}
+
+	h.entries = append(h.entries, input)
+	if len(h.entries) > MaxHistory {
+		h.entries = h.entries[len(h.entries)-MaxHistory:]
+	}
+
+	if h.path == "" {
+		return nil
+	}
+	var err error
+	if h.saved >= 2*MaxHistory {
+		err = h.rewrite()
+	} else {
+		err = h.append(input)
+	}
+	if err != nil {
+		h.path = ""
+	}
+	return err
+}
+
+// append adds input to the end of the history file.
+func (h *History) append(input string) error {
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(historyEscaper.Replace(input) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	h.saved++
+	return f.Close()
+}
+
+// rewrite replaces the history file with the entries in memory.
+func (h *History) rewrite() error {
+	var b strings.Builder
+	for _, input := range h.entries {
+		b.WriteString(historyEscaper.Replace(input) + "\n")
+	}
+	if err := os.WriteFile(h.path, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	h.saved = len(h.entries)
+	return nil
+}
+
+// historyEscaper escapes the newlines of an entry, and the backslashes
+// that could be mistaken for an escape.
+var historyEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// unescapeHistory undoes historyEscaper.
+func unescapeHistory(line string) string {
+	if !strings.Contains(line, `\`) {
+		return line
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) {
+			switch line[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(line[i])
+	}
+	return b.String()
+}
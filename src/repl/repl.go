@@ -0,0 +1,211 @@
+// Package repl implements the interactive Gorilla prompt.
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"gorilla/ast"
+	"gorilla/evaluator"
+	"gorilla/lexer"
+	"gorilla/object"
+	"gorilla/parser"
+	"gorilla/token"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	PROMPT              = ">> "
+	CONTINUATION_PROMPT = ".. "
+	GORILLA_FACE        = "🦍"
+)
+
+// Engine runs the programs typed into the REPL. It keeps the bindings of
+// every program for the next one.
+type Engine interface {
+	Run(program *ast.Program) object.Object
+
+	// Names returns the global names defined so far.
+	Names() []string
+
+	// Get returns the value of a global.
+	Get(name string) (object.Object, bool)
+
+	// Define binds a global to val.
+	Define(name string, val object.Object)
+
+	// Reset forgets every binding and imported module.
+	Reset()
+}
+
+// lineReader reads the lines of input, showing a prompt before each.
+type lineReader interface {
+	readLine(prompt string) (string, error)
+
+	// addHistory keeps a complete input, of one line or more, in the
+	// history if there is one.
+	addHistory(input string) error
+}
+
+// Start runs the REPL until in is exhausted. On a terminal lines can be
+// edited, are kept in the history file and names can be completed with
+// Tab; otherwise plain lines are read.
+func Start(in io.Reader, out io.Writer, engine Engine) {
+	var reader lineReader
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		reader = &terminalReader{
+			fd: int(f.Fd()),
+			editor: newEditor(f, out, LoadHistory(DefaultHistoryPath()), func(string) []string {
+				return append(engine.Names(), evaluator.BuiltinNames()...)
+			}),
+		}
+	} else {
+		reader = &plainReader{scanner: bufio.NewScanner(in), out: out}
+	}
+
+	run(reader, out, engine)
+}
+
+func run(reader lineReader, out io.Writer, engine Engine) {
+	var lines []string
+
+	for {
+		prompt := PROMPT
+		if len(lines) > 0 {
+			prompt = CONTINUATION_PROMPT
+		}
+
+		line, err := reader.readLine(prompt)
+		if errors.Is(err, errInterrupt) {
+			lines = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		if isIncomplete(input) {
+			continue
+		}
+		lines = nil
+
+		if err := reader.addHistory(input); err != nil {
+			fmt.Fprintf(out, "could not save the history: %s\n", err)
+		}
+
+		switch {
+		case strings.TrimSpace(input) == "":
+		case strings.HasPrefix(strings.TrimSpace(input), ":"):
+			runCommand(out, engine, strings.TrimSpace(input))
+		default:
+			if program, ok := parse(out, input, ""); ok {
+				printResult(out, engine.Run(program))
+			}
+		}
+	}
+}
+
+// parse parses the source of the given file, printing the parser errors
+// if there are any.
+func parse(out io.Writer, src, filename string) (*ast.Program, bool) {
+	p := parser.New(lexer.NewWithFile(src, filename))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		PrintParserErrors(out, p.Errors())
+		return nil, false
+	}
+	return program, true
+}
+
+// printResult prints the value of a program, or the error it stopped with.
+func printResult(out io.Writer, evaluated object.Object) {
+	if err, ok := evaluated.(*object.Error); ok {
+		io.WriteString(out, err.StackTrace()+"\n")
+		return
+	}
+	if evaluated != nil {
+		if evaluated != evaluator.NULL {
+			io.WriteString(out, evaluated.Inspect())
+		}
+		io.WriteString(out, "\n")
+	}
+}
+
+// isIncomplete reports whether input leaves a parenthesis, bracket, brace,
+// string or block comment open, so that the statement goes on in the next
+// line.
+func isIncomplete(input string) bool {
+	depth := 0
+
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE, token.TEMPLATE_START:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE, token.TEMPLATE_END:
+			depth--
+		case token.ILLEGAL:
+			if strings.HasPrefix(tok.Literal, "/*") || strings.HasPrefix(tok.Literal, `"`) ||
+				strings.HasPrefix(tok.Literal, "'") || strings.HasPrefix(tok.Literal, "}") {
+				return true
+			}
+		}
+	}
+
+	return depth > 0
+}
+
+// PrintParserErrors writes the errors that stopped a program from parsing.
+func PrintParserErrors(out io.Writer, errors []string) {
+	io.WriteString(out, GORILLA_FACE)
+	io.WriteString(out, "\nWoops! We ran into some gorilla business here!\n")
+	io.WriteString(out, " parser errors:\n")
+	for _, msg := range errors {
+		io.WriteString(out, "\t"+msg+"\n")
+	}
+}
+
+// plainReader reads lines that are not typed on a terminal, like piped
+// input.
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+func (r *plainReader) addHistory(input string) error { return nil }
+
+// terminalReader reads lines with the line editor, keeping the terminal in
+// raw mode only while a line is typed.
+type terminalReader struct {
+	fd     int
+	editor *editor
+}
+
+func (r *terminalReader) readLine(prompt string) (string, error) {
+	state, err := makeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	line, err := r.editor.readLine(prompt)
+	restore(r.fd, state)
+	return line, err
+}
+
+func (r *terminalReader) addHistory(input string) error {
+	return r.editor.history.Add(input)
+}
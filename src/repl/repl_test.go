@@ -0,0 +1,316 @@
+package repl
+
+import (
+	"bytes"
+	"gorilla/ast"
+	"gorilla/evaluator"
+	"gorilla/object"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testEngine struct {
+	env *object.Environment
+}
+
+func (e *testEngine) Run(program *ast.Program) object.Object {
+	return evaluator.Eval(program, e.env)
+}
+
+func (e *testEngine) Names() []string { return e.env.Names() }
+
+func (e *testEngine) Get(name string) (object.Object, bool) { return e.env.Get(name) }
+
+func (e *testEngine) Define(name string, val object.Object) { e.env.Set(name, val) }
+
+func (e *testEngine) Reset() { e.env = object.NewEnvironment() }
+
+func TestRunMultiLineInput(t *testing.T) {
+	input := `let add = fn(a, b) {
+	a + b
+};
+add(1,
+	2)
+[1,
+ 2][1]
+let x = (1 +
+`
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, &testEngine{env: object.NewEnvironment()})
+
+	expected := ">> .. .. >> .. 3\n>> .. 2\n>> .. "
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	input := "let = 1\nfoo\n1 + 1\n"
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, &testEngine{env: object.NewEnvironment()})
+
+	for _, expected := range []string{
+		"parser errors:",
+		"ERROR: 1:1: identifier not found: foo\n",
+		">> 2\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output doesn't contain %q. got=%q", expected, out.String())
+		}
+	}
+}
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 1;", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n x }", false},
+		{"[1, 2,", true},
+		{"len(", true},
+		{`"{"`, false},
+		{"}", false},
+		{"1 /* a", true},
+		{"1 /* a\n */", false},
+		{"1 # (", false},
+		{`"say \"`, true},
+		{"'a\nb'", false},
+		{`"a ${f(`, true},
+		{`"a ${x} b`, true},
+		{`"a ${ {"k": 1}["k"] } b"`, false},
+		{`"""`, true},
+		{"\"\"\"\n a\n\"\"\"", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. want=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestEditor(t *testing.T) {
+	tests := []struct {
+		keys     string
+		history  []string
+		expected string
+	}{
+		{"abc\r", nil, "abc"},
+		{"ac\x1b[Db\r", nil, "abc"},
+		{"abd\x7fc\r", nil, "abc"},
+		{"bc\x01a\x05d\r", nil, "abcd"},
+		{"abc\x1b[D\x1b[D\x1b[3~\r", nil, "ac"},
+		{"abc\x1b[D\x0b\r", nil, "ab"},
+		{"abc\x1b[D\x15\r", nil, "c"},
+		{"let foo\x17bar\r", nil, "let bar"},
+		{"\x1b[A\r", []string{"first", "second"}, "second"},
+		{"\x1b[A\x1b[A\r", []string{"first", "second"}, "first"},
+		{"new\x1b[A\x1b[B\r", []string{"old"}, "new"},
+		{"pu\t(\r", nil, "push("},
+		{"le\t\r", nil, "len"},
+		{"x\tyz\r", nil, "xyz"},
+		{"héllo\x1b[D\x7f\r", nil, "hélo"},
+	}
+
+	for _, tt := range tests {
+		history := LoadHistory("")
+		for _, line := range tt.history {
+			history.Add(line)
+		}
+
+		var out bytes.Buffer
+		e := newEditor(strings.NewReader(tt.keys), &out, history, func(string) []string {
+			return evaluator.BuiltinNames()
+		})
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("readLine(%q) failed: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("readLine(%q) wrong. want=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestEditorCompletionList(t *testing.T) {
+	var out bytes.Buffer
+	e := newEditor(strings.NewReader("re\t\r"), &out, LoadHistory(""), func(string) []string {
+		return []string{"readint", "readline", "rest", "len"}
+	})
+	if line, _ := e.readLine(PROMPT); line != "re" {
+		t.Errorf("ambiguous completion changed the line. got=%q", line)
+	}
+	if !strings.Contains(out.String(), "readint  readline  rest") {
+		t.Errorf("completions not listed. got=%q", out.String())
+	}
+}
+
+func TestEditorControlKeys(t *testing.T) {
+	e := newEditor(strings.NewReader("\x04"), &bytes.Buffer{}, LoadHistory(""), nil)
+	if _, err := e.readLine(PROMPT); err == nil || err.Error() != "EOF" {
+		t.Errorf("Ctrl-D on an empty line didn't return EOF. got=%v", err)
+	}
+
+	e = newEditor(strings.NewReader("abc\x03"), &bytes.Buffer{}, LoadHistory(""), nil)
+	if _, err := e.readLine(PROMPT); err != errInterrupt {
+		t.Errorf("Ctrl-C didn't interrupt. got=%v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HistoryFile)
+
+	h := LoadHistory(path)
+	for _, input := range []string{"let a = 1", "let a = 1", "  ", "a + 1", "let f = fn() {\n\t\"\\n\"\n}"} {
+		if err := h.Add(input); err != nil {
+			t.Fatalf("Add(%q) failed: %s", input, err)
+		}
+	}
+
+	h = LoadHistory(path)
+	expected := []string{"let a = 1", "a + 1", "let f = fn() {\n\t\"\\n\"\n}"}
+	if h.Len() != len(expected) {
+		t.Fatalf("wrong history after reloading. got=%q", h.entries)
+	}
+	for i, input := range expected {
+		if h.Get(i) != input {
+			t.Errorf("wrong entry %d after reloading. want=%q, got=%q", i, input, h.Get(i))
+		}
+	}
+
+	// The file keeps growing up to twice MaxHistory entries, and is then
+	// cut down to the entries in memory
+	countLines := func() int {
+		dat, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(dat), "\n")
+	}
+	for i := h.Len(); i < 2*MaxHistory; i++ {
+		h.Add(strings.Repeat("x", i+1))
+	}
+	if h.Len() != MaxHistory {
+		t.Errorf("history not trimmed. want=%d entries, got=%d", MaxHistory, h.Len())
+	}
+	if lines := countLines(); lines != 2*MaxHistory {
+		t.Errorf("history file rewritten too early. want=%d lines, got=%d", 2*MaxHistory, lines)
+	}
+
+	h = LoadHistory(path)
+	if h.Len() != MaxHistory || h.Get(MaxHistory-1) != strings.Repeat("x", 2*MaxHistory) {
+		t.Errorf("wrong history after reloading. got %d entries", h.Len())
+	}
+	h.Add("last")
+	if lines := countLines(); lines != MaxHistory {
+		t.Errorf("history file not trimmed. want=%d lines, got=%d", MaxHistory, lines)
+	}
+}
+
+func TestHistoryWriteError(t *testing.T) {
+	h := LoadHistory(filepath.Join(t.TempDir(), "missing", HistoryFile))
+	if err := h.Add("1"); err == nil {
+		t.Errorf("no error saving history in a missing directory")
+	}
+	if err := h.Add("2"); err != nil {
+		t.Errorf("history still saved after an error: %s", err)
+	}
+	if h.Len() != 2 {
+		t.Errorf("history not kept in memory. got=%q", h.entries)
+	}
+}
+
+// scriptReader reads the given lines as if they were typed at a terminal.
+type scriptReader struct {
+	lines   []string
+	history *History
+}
+
+func (r *scriptReader) readLine(prompt string) (string, error) {
+	if len(r.lines) == 0 {
+		return "", io.EOF
+	}
+	line := r.lines[0]
+	r.lines = r.lines[1:]
+	return line, nil
+}
+
+func (r *scriptReader) addHistory(input string) error { return r.history.Add(input) }
+
+func TestRunHistory(t *testing.T) {
+	reader := &scriptReader{
+		lines:   []string{"let add = fn(a, b) {", "a + b", "}", "add(1, 2)", "let x = (1 +"},
+		history: LoadHistory(""),
+	}
+	run(reader, &bytes.Buffer{}, &testEngine{env: object.NewEnvironment()})
+
+	expected := []string{"let add = fn(a, b) {\na + b\n}", "add(1, 2)"}
+	if reader.history.Len() != len(expected) {
+		t.Fatalf("wrong history. got=%q", reader.history.entries)
+	}
+	for i, input := range expected {
+		if reader.history.Get(i) != input {
+			t.Errorf("wrong entry %d. want=%q, got=%q", i, input, reader.history.Get(i))
+		}
+	}
+
+	// An entry of several lines is brought back whole
+	e := newEditor(strings.NewReader("\x1b[A\x1b[A\r"), &bytes.Buffer{}, reader.history, nil)
+	if line, _ := e.readLine(PROMPT); line != expected[0] {
+		t.Errorf("wrong line from history. want=%q, got=%q", expected[0], line)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.gor")
+	if err := os.WriteFile(lib, []byte("let triple = fn(x) { x * 3 };"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":tokens let x = 1", "1:1    LET        \"let\"\n1:5    IDENT      \"x\"\n" +
+			"1:7    =          \"=\"\n1:9    INT        \"1\"\n"},
+		{":ast -x", "Program @1:1\n  Statements[0]: ExpressionStatement @1:1\n" +
+			"    Expression: PrefixExpression Operator=\"-\" @1:1\n      Right: Identifier Value=\"x\" @1:2\n"},
+		{":ast let = 1", "parser errors:"},
+		{"let a = 1; let b = [a]; :env", "a: INTEGER = 1\nb: ARRAY = [1]\n"},
+		{`let f = fn(x) { x }; :env`, "f: FUNCTION = fn(x) { x }\n"},
+		{`:type "s"`, "STRING\n"},
+		{":type let x = 1", "no value\n"},
+		{":type foo", "identifier not found: foo"},
+		{":load " + lib + "\ntriple(2)", ">> 6\n"},
+		{":load " + filepath.Join(dir, "missing.gor"), "no such file or directory"},
+		{"let a = 1\n:reset\na", "identifier not found: a"},
+		{":time 1 + 2", "3\ntime: "},
+		{":time", "usage: :time <code>\n"},
+		{":help", "  :tokens <code>  show the tokens the lexer reads from code\n"},
+		{":nope", "unknown command :nope, type :help for a list\n"},
+		{":time fn() {\n 1 }()", ">> .. 1\ntime: "},
+	}
+
+	for _, tt := range tests {
+		// Commands start a line, so what comes before one goes first
+		input := tt.input
+		if before, cmd, ok := strings.Cut(input, "; :"); ok {
+			input = before + "\n:" + cmd
+		}
+
+		var out bytes.Buffer
+		Start(strings.NewReader(input+"\n"), &out, &testEngine{env: object.NewEnvironment()})
+		if !strings.Contains(out.String(), tt.expected) {
+			t.Errorf("wrong output for %q. want it to contain %q, got=%q", tt.input, tt.expected, out.String())
+		}
+	}
+}
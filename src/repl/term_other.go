@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package repl
+
+import "errors"
+
+// termState is the terminal configuration to restore after raw mode.
+type termState struct{}
+
+// Raw mode isn't supported here, so the REPL reads plain lines.
+func isTerminal(fd int) bool { return false }
+
+func makeRaw(fd int) (*termState, error) {
+	return nil, errors.New("raw mode not supported")
+}
+
+func restore(fd int, state *termState) error { return nil }
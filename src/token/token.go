@@ -1,21 +1,57 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
+// Position is a location in the source. Line and Column start at 1, the
+// zero value means "no position". Column counts chars, not bytes.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns "file:line:col", or "line:col" when there is no file name.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	s := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.Filename != "" {
+		s = p.Filename + ":" + s
+	}
+	return s
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // position of the first character of the token
+	End     Position // position just past the last character of the token
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // # line, /* block */ or ## doc comment
 
 	// Identifiers + literals
 	IDENT  = "IDENT" // add, foobar, x, y, ...
 	INT    = "INT"   // 1343456
+	FLOAT  = "FLOAT" // 3.14, 1e-9
 	STRING = "STRING"
 
+	// The text parts of an interpolated string, "a ${x} b ${y} c"
+	TEMPLATE_START  = "TEMPLATE_START"  // "a ${
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE" // } b ${
+	TEMPLATE_END    = "TEMPLATE_END"    // } c"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -25,10 +61,20 @@ const (
 	SLASH    = "/"
 	LT       = "<"
 	GT       = ">"
+	LT_EQ    = "<="
+	GT_EQ    = ">="
 	EQ       = "=="
 	NOT_EQ   = "!="
+	AND      = "&&"
+	OR       = "||"
 	MODULO   = "%"
 
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	MODULO_ASSIGN   = "%="
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -39,6 +85,7 @@ const (
 	LBRACKET  = "["
 	RBRACKET  = "]"
 	COLON     = ":"
+	DOT       = "."
 
 	// Keywords
 	FUNCTION = "FUNCTION"
@@ -49,17 +96,37 @@ const (
 	ELSE     = "ELSE"
 	WHILE    = "WHILE"
 	RETURN   = "RETURN"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	FOR      = "FOR"
+	IN       = "IN"
+	IMPORT   = "IMPORT"
+	AS       = "AS"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"while":  WHILE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"while":    WHILE,
+	"return":   RETURN,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"for":      FOR,
+	"in":       IN,
+	"import":   IMPORT,
+	"as":       AS,
 }
 
 func LookupIdent(ident string) TokenType {
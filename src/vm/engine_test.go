@@ -0,0 +1,23 @@
+package vm_test
+
+import (
+	"gorilla/ast"
+	"gorilla/compiler"
+	"gorilla/enginetest"
+	"gorilla/object"
+	"gorilla/vm"
+	"testing"
+)
+
+func TestEngine(t *testing.T) {
+	enginetest.Run(t, func(program *ast.Program, session *object.Session) object.Object {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return &object.Error{Message: "compiler error: " + err.Error()}
+		}
+
+		globals := vm.NewGlobals()
+		globals.Session = session
+		return vm.NewWithGlobals(comp.Bytecode(), globals).Run()
+	})
+}
@@ -0,0 +1,57 @@
+package vm
+
+import (
+	"gorilla/ast"
+	"gorilla/compiler"
+	"gorilla/evaluator"
+	"gorilla/object"
+	"strings"
+)
+
+// importModule returns the module importPath refers to, imported the same
+// way the evaluator does, but compiled and run on a VM of its own.
+func importModule(session *object.Session, importPath, importer string) (object.Object, *object.Error) {
+	return evaluator.Import(session, importPath, importer,
+		func(path string, program *ast.Program) (object.Object, *object.Error) {
+			comp := compiler.New()
+			if err := comp.Compile(program); err != nil {
+				return nil, newError("could not compile module %s: %s", importPath, err)
+			}
+			bytecode := comp.Bytecode()
+
+			globals := &Globals{Values: make([]object.Object, len(bytecode.GlobalNames)), Session: session}
+			result := NewWithGlobals(bytecode, globals).Run()
+			if err, ok := result.(*object.Error); ok {
+				return nil, err
+			}
+
+			module := &Module{
+				Name:    evaluator.ModuleName(path),
+				Path:    path,
+				globals: globals,
+				index:   make(map[string]int, len(globals.Names)),
+			}
+			for i, name := range globals.Names {
+				module.index[name] = i
+			}
+			return module, nil
+		})
+}
+
+// member returns the exported binding name of a module.
+func member(obj object.Object, name string) object.Object {
+	module, ok := obj.(*Module)
+	if !ok {
+		return newError("member access not supported: %s", obj.Type())
+	}
+
+	if strings.HasPrefix(name, "_") {
+		return newError("%s is not exported by module %s", name, module.Name)
+	}
+
+	i, ok := module.index[name]
+	if !ok || module.globals.Values[i] == nil {
+		return newError("module %s has no member %s", module.Name, name)
+	}
+	return module.globals.Values[i]
+}
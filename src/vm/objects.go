@@ -0,0 +1,146 @@
+package vm
+
+import (
+	"fmt"
+	"gorilla/object"
+)
+
+const (
+	CELL_OBJ     = "CELL"
+	ITERATOR_OBJ = "ITERATOR"
+)
+
+// Globals holds the top-level bindings of a program or module, and the
+// session it runs in.
+type Globals struct {
+	Names   []string
+	Values  []object.Object
+	Session *object.Session
+}
+
+// NewGlobals returns the globals of a program that can keep growing, like
+// the one of the REPL, with a session of its own.
+func NewGlobals() *Globals {
+	return &Globals{Values: make([]object.Object, GlobalsSize), Session: object.NewSession()}
+}
+
+// Closure is a compiled function together with the variables it captured
+// and the constants and globals of the file it was defined in. To Gorilla
+// code it is just a FUNCTION.
+type Closure struct {
+	Fn   *object.CompiledFunction
+	Free []*Cell
+
+	constants []object.Object
+	globals   *Globals
+}
+
+func (c *Closure) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (c *Closure) Inspect() string         { return c.Fn.Inspect() }
+
+// Cell holds a local variable that closures capture, so that the function
+// declaring it and the closures all see the same binding.
+type Cell struct {
+	Value object.Object
+}
+
+func (c *Cell) Type() object.ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string         { return fmt.Sprintf("cell(%v)", c.Value) }
+
+// Module is the namespace an imported file is bound to.
+type Module struct {
+	Name    string
+	Path    string
+	globals *Globals
+	index   map[string]int
+}
+
+func (m *Module) Type() object.ObjectType { return object.MODULE_OBJ }
+func (m *Module) Inspect() string         { return fmt.Sprintf("<module %s>", m.Name) }
+
+// iterator walks the values a for loop goes over.
+type iterator struct {
+	next func() (key, value object.Object, ok bool)
+	hash bool // a single loop variable gets the key, not the value
+}
+
+func (it *iterator) Type() object.ObjectType { return ITERATOR_OBJ }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+// newIterator returns an iterator over the index and element of an array,
+// key and value of a hash (in insertion order), index and character of a
+// string, or index and value of a range.
+func newIterator(iterable object.Object) (*iterator, *object.Error) {
+	i := 0
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		// The length is checked on every step so that the loop sees
+		// elements changed by the body
+		return &iterator{next: func() (object.Object, object.Object, bool) {
+			if i >= len(iterable.Elements) {
+				return nil, nil, false
+			}
+			i++
+			return integer(int64(i - 1)), iterable.Elements[i-1], true
+		}}, nil
+
+	case *object.Hash:
+		pairs := iterable.Entries()
+		return &iterator{hash: true, next: func() (object.Object, object.Object, bool) {
+			for i < len(pairs) {
+				key := pairs[i].Key
+				i++
+				if value, ok := iterable.Get(key); ok {
+					return key, value, true
+				}
+			}
+			return nil, nil, false
+		}}, nil
+
+	case *object.String:
+		chars := []rune(iterable.Value)
+		return &iterator{next: func() (object.Object, object.Object, bool) {
+			if i >= len(chars) {
+				return nil, nil, false
+			}
+			i++
+			return integer(int64(i - 1)), &object.String{Value: string(chars[i-1])}, true
+		}}, nil
+
+	case *object.Range:
+		length := iterable.Len()
+		return &iterator{next: func() (object.Object, object.Object, bool) {
+			if int64(i) >= length {
+				return nil, nil, false
+			}
+			i++
+			n := int64(i - 1)
+			return integer(n), integer(iterable.Start + n*iterable.Step), true
+		}}, nil
+
+	default:
+		return nil, newError("cannot iterate over %s", iterable.Type())
+	}
+}
+
+// Small integers are shared instead of allocated by every operation.
+const (
+	minCachedInt = -256
+	maxCachedInt = 1024
+)
+
+var smallInts = func() []*object.Integer {
+	ints := make([]*object.Integer, maxCachedInt-minCachedInt+1)
+	for i := range ints {
+		ints[i] = &object.Integer{Value: int64(i + minCachedInt)}
+	}
+	return ints
+}()
+
+func integer(value int64) *object.Integer {
+	if value >= minCachedInt && value <= maxCachedInt {
+		return smallInts[value-minCachedInt]
+	}
+	return &object.Integer{Value: value}
+}
@@ -0,0 +1,739 @@
+// Package vm runs the bytecode produced by the compiler package on a value
+// stack with call frames. Operators, indexing and builtins are shared with
+// the evaluator package, so both engines give the same results.
+package vm
+
+import (
+	"fmt"
+	"gorilla/code"
+	"gorilla/compiler"
+	"gorilla/debug"
+	"gorilla/evaluator"
+	"gorilla/object"
+	"gorilla/token"
+	"strings"
+)
+
+const (
+	StackSize   = 2048
+	GlobalsSize = 65536
+)
+
+var (
+	NULL  = evaluator.NULL
+	TRUE  = evaluator.TRUE
+	FALSE = evaluator.FALSE
+)
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpGreaterEqual: ">=",
+	code.OpLessThan:     "<",
+	code.OpLessEqual:    "<=",
+}
+
+type VM struct {
+	stack []object.Object
+	sp    int // Always points to the next value. Top of stack is stack[sp-1]
+
+	frames      []Frame
+	framesIndex int
+
+	handlers []handler
+}
+
+// handler is an active try block: an error raised in its frame resets the
+// stack to sp and continues at catchIP.
+type handler struct {
+	frame   int
+	sp      int
+	catchIP int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobals(bytecode, NewGlobals())
+}
+
+// NewWithGlobals returns a VM that runs bytecode with the given globals,
+// which may already hold the bindings of earlier runs.
+func NewWithGlobals(bytecode *compiler.Bytecode, globals *Globals) *VM {
+	globals.Names = bytecode.GlobalNames
+
+	mainFn := &object.CompiledFunction{
+		Name:         "<main>",
+		Instructions: bytecode.Instructions,
+		NumLocals:    len(bytecode.LocalNames),
+		LocalNames:   bytecode.LocalNames,
+		SourceMap:    bytecode.SourceMap,
+		CallNames:    bytecode.CallNames,
+	}
+	mainClosure := &Closure{Fn: mainFn, constants: bytecode.Constants, globals: globals}
+
+	vm := &VM{
+		stack:  make([]object.Object, StackSize),
+		sp:     mainFn.NumLocals,
+		frames: make([]Frame, 64),
+	}
+	vm.frames[0] = Frame{cl: mainClosure}
+	vm.framesIndex = 1
+	return vm
+}
+
+// Run executes the program and returns the value of its last statement, or
+// the error that stopped it.
+func (vm *VM) Run() object.Object {
+	return vm.run(0)
+}
+
+// run executes instructions until the frame at index base returns, and
+// returns its result. An error that isn't caught in that frame or the ones
+// it called is returned as well.
+func (vm *VM) run(base int) object.Object {
+	for {
+		frame := &vm.frames[vm.framesIndex-1]
+		ins := frame.cl.Fn.Instructions
+		frame.op = frame.ip
+		op := code.Opcode(ins[frame.ip])
+		frame.ip++
+
+		if debug.PRINTEVALUATION {
+			vm.traceInstruction(frame)
+		}
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			vm.push(frame.cl.constants[constIndex])
+
+		case code.OpPop:
+			vm.sp--
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterEqual,
+			code.OpLessThan, code.OpLessEqual:
+			err = vm.executeBinaryOperation(op)
+
+		case code.OpTrue:
+			vm.push(TRUE)
+		case code.OpFalse:
+			vm.push(FALSE)
+		case code.OpNull:
+			vm.push(NULL)
+
+		case code.OpMinus:
+			operand := vm.stack[vm.sp-1]
+			if i, ok := operand.(*object.Integer); ok {
+				vm.stack[vm.sp-1] = integer(-i.Value)
+			} else {
+				err = vm.replaceTop(evaluator.EvalPrefix("-", operand))
+			}
+
+		case code.OpBang:
+			vm.stack[vm.sp-1] = nativeBoolToBooleanObject(!evaluator.IsTruthy(vm.stack[vm.sp-1]))
+
+		case code.OpBool:
+			vm.stack[vm.sp-1] = nativeBoolToBooleanObject(evaluator.IsTruthy(vm.stack[vm.sp-1]))
+
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[frame.ip:]))
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = pos
+			}
+
+		case code.OpGetGlobal:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			globals := frame.cl.globals
+			if val := globals.Values[idx]; val != nil {
+				vm.push(val)
+			} else {
+				err = newError("identifier not found: %s", globals.Names[idx])
+			}
+
+		case code.OpSetGlobal:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			frame.cl.globals.Values[idx] = vm.pop()
+
+		case code.OpAssignGlobal:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			globals := frame.cl.globals
+			if globals.Values[idx] != nil {
+				globals.Values[idx] = vm.stack[vm.sp-1]
+			} else {
+				err = newError("cannot assign to undeclared identifier: %s", globals.Names[idx])
+			}
+
+		case code.OpGetLocal:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			if val := vm.stack[frame.bp+idx]; val != nil {
+				vm.push(val)
+			} else {
+				err = newError("identifier not found: %s", frame.cl.Fn.LocalNames[idx])
+			}
+
+		case code.OpSetLocal:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			vm.stack[frame.bp+idx] = vm.pop()
+
+		case code.OpAssignLocal:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			if vm.stack[frame.bp+idx] != nil {
+				vm.stack[frame.bp+idx] = vm.stack[vm.sp-1]
+			} else {
+				err = newError("cannot assign to undeclared identifier: %s", frame.cl.Fn.LocalNames[idx])
+			}
+
+		case code.OpClearLocal:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			vm.stack[frame.bp+idx] = nil
+
+		case code.OpMakeCell:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			vm.stack[frame.bp+idx] = &Cell{Value: vm.stack[frame.bp+idx]}
+
+		case code.OpGetCell:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			if val := vm.stack[frame.bp+idx].(*Cell).Value; val != nil {
+				vm.push(val)
+			} else {
+				err = newError("identifier not found: %s", frame.cl.Fn.LocalNames[idx])
+			}
+
+		case code.OpSetCell:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			vm.stack[frame.bp+idx].(*Cell).Value = vm.pop()
+
+		case code.OpAssignCell:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			cell := vm.stack[frame.bp+idx].(*Cell)
+			if cell.Value != nil {
+				cell.Value = vm.stack[vm.sp-1]
+			} else {
+				err = newError("cannot assign to undeclared identifier: %s", frame.cl.Fn.LocalNames[idx])
+			}
+
+		case code.OpGetFree:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			if val := frame.cl.Free[idx].Value; val != nil {
+				vm.push(val)
+			} else {
+				err = newError("identifier not found: %s", frame.cl.Fn.FreeNames[idx])
+			}
+
+		case code.OpAssignFree:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			cell := frame.cl.Free[idx]
+			if cell.Value != nil {
+				cell.Value = vm.stack[vm.sp-1]
+			} else {
+				err = newError("cannot assign to undeclared identifier: %s", frame.cl.Fn.FreeNames[idx])
+			}
+
+		case code.OpGetFreeCell:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			vm.push(frame.cl.Free[idx])
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			vm.push(&object.Array{Elements: elements})
+
+		case code.OpTemplate:
+			numParts := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			parts := make([]object.Object, numParts)
+			copy(parts, vm.stack[vm.sp-numParts:vm.sp])
+			vm.sp -= numParts
+			vm.push(evaluator.Interpolate(parts))
+
+		case code.OpHash:
+			numPairs := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			err = vm.buildHash(numPairs)
+
+		case code.OpIndex:
+			index := vm.pop()
+			err = vm.replaceTop(evaluator.EvalIndex(vm.stack[vm.sp-1], index))
+
+		case code.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			err = vm.replaceTop(evaluator.EvalIndexAssignment(vm.stack[vm.sp-1], index, val))
+
+		case code.OpDup2:
+			vm.push(vm.stack[vm.sp-2])
+			vm.push(vm.stack[vm.sp-2])
+
+		case code.OpCall:
+			numArgs := int(ins[frame.ip])
+			frame.ip++
+			err = vm.executeCall(numArgs)
+
+		case code.OpReturnValue, code.OpReturn:
+			var returnValue object.Object
+			if op == code.OpReturnValue {
+				returnValue = vm.pop()
+			}
+
+			vm.framesIndex--
+			vm.dropHandlers()
+			if vm.framesIndex == base {
+				return returnValue
+			}
+
+			vm.sp = frame.bp - 1
+			if returnValue == nil {
+				returnValue = NULL
+			}
+			vm.push(returnValue)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[frame.ip:])
+			numFree := int(ins[frame.ip+2])
+			frame.ip += 3
+
+			free := make([]*Cell, numFree)
+			for i := 0; i < numFree; i++ {
+				free[i] = vm.stack[vm.sp-numFree+i].(*Cell)
+			}
+			vm.sp -= numFree
+
+			vm.push(&Closure{
+				Fn:        frame.cl.constants[constIndex].(*object.CompiledFunction),
+				Free:      free,
+				constants: frame.cl.constants,
+				globals:   frame.cl.globals,
+			})
+
+		case code.OpIter:
+			it, iterErr := newIterator(vm.stack[vm.sp-1])
+			if iterErr != nil {
+				err = iterErr
+			} else {
+				vm.stack[vm.sp-1] = it
+			}
+
+		case code.OpIterNext:
+			exit := int(code.ReadUint16(ins[frame.ip:]))
+			numValues := int(ins[frame.ip+2])
+			frame.ip += 3
+
+			it := vm.stack[vm.sp-2].(*iterator)
+			key, value, ok := it.next()
+			if !ok {
+				frame.ip = exit
+				break
+			}
+
+			// Drop the result of the previous run of the body
+			vm.sp--
+			switch {
+			case numValues == 2:
+				vm.push(key)
+				vm.push(value)
+			case it.hash:
+				vm.push(key)
+			default:
+				vm.push(value)
+			}
+
+		case code.OpIterEnd:
+			result := vm.pop()
+			vm.stack[vm.sp-1] = result
+
+		case code.OpPushHandler:
+			catchIP := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			vm.handlers = append(vm.handlers, handler{
+				frame:   vm.framesIndex - 1,
+				sp:      vm.sp,
+				catchIP: catchIP,
+			})
+
+		case code.OpPopHandler:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpThrow:
+			err = evaluator.ThrownError(vm.pop())
+
+		case code.OpRethrow:
+			err = vm.pop().(*object.Error)
+
+		case code.OpCaught:
+			vm.stack[vm.sp-1] = evaluator.CaughtValue(vm.stack[vm.sp-1].(*object.Error))
+
+		case code.OpImport:
+			constIndex := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			path := frame.cl.constants[constIndex].(*object.String).Value
+			importer := frame.cl.Fn.SourceMap.Lookup(frame.op).Filename
+
+			module, importErr := importModule(frame.cl.globals.Session, path, importer)
+			if importErr != nil {
+				err = importErr
+			} else {
+				vm.push(module)
+			}
+
+		case code.OpMember:
+			constIndex := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			name := frame.cl.constants[constIndex].(*object.String).Value
+			err = vm.replaceTop(member(vm.stack[vm.sp-1], name))
+
+		default:
+			err = newError("unknown opcode %d", op)
+		}
+
+		if err != nil && !vm.handleError(err, base) {
+			return err
+		}
+	}
+}
+
+func (vm *VM) push(o object.Object) {
+	if vm.sp >= len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// replaceTop replaces the value on top of the stack by result, unless the
+// result is an error, which is returned instead.
+func (vm *VM) replaceTop(result object.Object) *object.Error {
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+	vm.stack[vm.sp-1] = result
+	return nil
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) *object.Error {
+	right := vm.pop()
+	left := vm.stack[vm.sp-1]
+
+	// Integer arithmetic and comparisons are by far the most common, so
+	// they don't go through the generic operator code
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case code.OpAdd:
+				vm.stack[vm.sp-1] = integer(l.Value + r.Value)
+				return nil
+			case code.OpSub:
+				vm.stack[vm.sp-1] = integer(l.Value - r.Value)
+				return nil
+			case code.OpMul:
+				vm.stack[vm.sp-1] = integer(l.Value * r.Value)
+				return nil
+			case code.OpEqual:
+				vm.stack[vm.sp-1] = nativeBoolToBooleanObject(l.Value == r.Value)
+				return nil
+			case code.OpNotEqual:
+				vm.stack[vm.sp-1] = nativeBoolToBooleanObject(l.Value != r.Value)
+				return nil
+			case code.OpGreaterThan:
+				vm.stack[vm.sp-1] = nativeBoolToBooleanObject(l.Value > r.Value)
+				return nil
+			case code.OpGreaterEqual:
+				vm.stack[vm.sp-1] = nativeBoolToBooleanObject(l.Value >= r.Value)
+				return nil
+			case code.OpLessThan:
+				vm.stack[vm.sp-1] = nativeBoolToBooleanObject(l.Value < r.Value)
+				return nil
+			case code.OpLessEqual:
+				vm.stack[vm.sp-1] = nativeBoolToBooleanObject(l.Value <= r.Value)
+				return nil
+			}
+		}
+	}
+
+	return vm.replaceTop(evaluator.EvalInfix(operators[op], left, right))
+}
+
+func (vm *VM) buildHash(numPairs int) *object.Error {
+	start := vm.sp - 2*numPairs
+	hash := object.NewHash()
+
+	for i := start; i < vm.sp; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := object.AsHashable(key)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+		hash.Set(hashKey, value)
+	}
+
+	vm.sp = start
+	vm.push(hash)
+	return nil
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *Closure:
+		// A call that fails before its frame is pushed still shows up in
+		// the stack trace, as it does in the evaluator
+		err := vm.callClosure(callee, numArgs)
+		if err != nil {
+			vm.addCallFrame(err, callee.Fn.Name, vm.stack[vm.sp-numArgs:vm.sp])
+		}
+		return err
+
+	case *object.Builtin:
+		args := vm.stack[vm.sp-numArgs : vm.sp]
+		result := callee.Call(vm.call, args...)
+		if err, ok := result.(*object.Error); ok {
+			vm.addCallFrame(err, "", args)
+			return err
+		}
+
+		vm.sp = vm.sp - numArgs - 1
+		vm.push(result)
+		return nil
+
+	default:
+		err := newError("not a function: %s", callee.Type())
+		vm.addCallFrame(err, "", vm.stack[vm.sp-numArgs:vm.sp])
+		return err
+	}
+}
+
+func (vm *VM) callClosure(cl *Closure, numArgs int) *object.Error {
+	fn := cl.Fn
+	if numArgs < fn.NumParameters {
+		return newError("wrong number of arguments: want=%d, got=%d",
+			fn.NumParameters, numArgs)
+	}
+	if max := cl.globals.Session.MaxDepth; max > 0 && vm.framesIndex > max {
+		return newError("stack overflow")
+	}
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, make([]Frame, len(vm.frames))...)
+	}
+
+	bp := vm.sp - numArgs
+	for bp+fn.NumLocals+1 >= len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+
+	// Extra arguments are dropped, and the locals start out undefined
+	for i := bp + fn.NumParameters; i < bp+fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+
+	vm.frames[vm.framesIndex] = Frame{cl: cl, bp: bp}
+	vm.framesIndex++
+	vm.sp = bp + fn.NumLocals
+	return nil
+}
+
+// call runs fn with args to completion and returns its result. It is the
+// object.Caller through which builtins call back functions, in the middle
+// of the instruction that called the builtin.
+func (vm *VM) call(fn object.Object, args ...object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *Closure:
+		sp := vm.sp
+		vm.push(fn)
+		for _, arg := range args {
+			vm.push(arg)
+		}
+		if err := vm.callClosure(fn, len(args)); err != nil {
+			vm.sp = sp
+			return err
+		}
+
+		// An error that isn't caught stops in the frame of fn, so it is
+		// left here
+		base := vm.framesIndex - 1
+		result := vm.run(base)
+		if _, ok := result.(*object.Error); ok {
+			vm.framesIndex = base
+			vm.dropHandlers()
+		}
+		vm.sp = sp
+
+		if result == nil {
+			return NULL
+		}
+		return result
+
+	case *object.Builtin:
+		return fn.Call(vm.call, args...)
+
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+// addCallFrame records on err the call the current frame is executing, to
+// a function that is not on the frame stack: a builtin, a non-function or
+// a function that couldn't be called.
+func (vm *VM) addCallFrame(err *object.Error, name string, args []object.Object) {
+	frame := &vm.frames[vm.framesIndex-1]
+	err.Stack = append(err.Stack, object.StackFrame{
+		Function: frame.calleeName(name),
+		Pos:      frame.position(),
+		Args:     append([]object.Object(nil), args...),
+	})
+}
+
+// handleError raises err in the current frame. It unwinds the frames above
+// base until it finds a try block to continue in, recording each call it
+// leaves in the stack trace of err. It reports whether err was caught.
+func (vm *VM) handleError(err *object.Error, base int) bool {
+	if !err.Pos.IsValid() {
+		err.Pos = vm.frames[vm.framesIndex-1].position()
+	}
+
+	for {
+		if n := len(vm.handlers); n > 0 && vm.handlers[n-1].frame == vm.framesIndex-1 {
+			h := vm.handlers[n-1]
+			vm.handlers = vm.handlers[:n-1]
+
+			vm.sp = h.sp
+			vm.push(err)
+			vm.frames[vm.framesIndex-1].ip = h.catchIP
+			return true
+		}
+
+		if vm.framesIndex-1 == base {
+			return false
+		}
+
+		frame := &vm.frames[vm.framesIndex-1]
+		caller := &vm.frames[vm.framesIndex-2]
+		err.Stack = append(err.Stack, object.StackFrame{
+			Function: caller.calleeName(frame.cl.Fn.Name),
+			Pos:      caller.position(),
+			Args:     vm.arguments(frame),
+		})
+
+		vm.framesIndex--
+		vm.dropHandlers()
+		vm.sp = frame.bp - 1
+	}
+}
+
+// dropHandlers discards the handlers of frames that have been left.
+func (vm *VM) dropHandlers() {
+	n := len(vm.handlers)
+	for n > 0 && vm.handlers[n-1].frame >= vm.framesIndex {
+		n--
+	}
+	vm.handlers = vm.handlers[:n]
+}
+
+// arguments returns the current values of the parameters of frame.
+func (vm *VM) arguments(frame *Frame) []object.Object {
+	args := make([]object.Object, frame.cl.Fn.NumParameters)
+	for i := range args {
+		arg := vm.stack[frame.bp+i]
+		if cell, ok := arg.(*Cell); ok {
+			arg = cell.Value
+		}
+		args[i] = arg
+	}
+	return args
+}
+
+// Frame is the activation of a function call.
+type Frame struct {
+	cl *Closure
+	ip int // offset of the next instruction
+	op int // offset of the instruction being executed
+	bp int // where the locals start on the stack
+}
+
+// position returns the source position of the instruction being executed.
+func (f *Frame) position() token.Position {
+	return f.cl.Fn.SourceMap.Lookup(f.op)
+}
+
+// calleeName returns the name a call made by f shows up as in a stack
+// trace: the name of the function, or else the name it was called by.
+func (f *Frame) calleeName(name string) string {
+	if name != "" {
+		return name
+	}
+	if name, ok := f.cl.Fn.CallNames[f.op]; ok {
+		return name
+	}
+	return "<anonymous>"
+}
+
+// traceInstruction prints the instruction frame is about to execute,
+// indented by the depth of the call.
+func (vm *VM) traceInstruction(frame *Frame) {
+	ins := frame.cl.Fn.Instructions
+	def, err := code.Lookup(ins[frame.op])
+	if err != nil {
+		return
+	}
+	operands, _ := code.ReadOperands(def, ins[frame.op+1:])
+
+	name := frame.cl.Fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	fmt.Printf("%s%s %04d %s", strings.Repeat("  ", vm.framesIndex-1), name, frame.op, def.Name)
+	for _, operand := range operands {
+		fmt.Printf(" %d", operand)
+	}
+	fmt.Println()
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}